@@ -5,6 +5,9 @@ import "errors"
 var (
 	errInvalidState = errors.New("invalid state")
 	errInterrupted  = errors.New("interrupted")
+	errDependency   = errors.New("invalid dependency")
+	errUnsupported  = errors.New("unsupported operation")
+	errTimeout      = errors.New("timeout")
 )
 
 // IsInvalidState returns true if the cause of the error is an invalid initial
@@ -20,3 +23,24 @@ func IsInvalidState(err error) bool {
 func IsInterrupted(err error) bool {
 	return errors.Is(err, errInterrupted)
 }
+
+// IsInvalidDependency returns true if the cause of the error is an invalid
+// dependency between services, such as a dependency cycle or a dependency on a
+// service that is not managed by the same host.
+func IsInvalidDependency(err error) bool {
+	return errors.Is(err, errDependency)
+}
+
+// IsUnsupported returns true if the cause of the error is an unsupported
+// operation, for example reloading a service which does not define a Reload
+// hook.
+func IsUnsupported(err error) bool {
+	return errors.Is(err, errUnsupported)
+}
+
+// IsTimeout returns true if the cause of the error is a timeout, for example a
+// readiness probe not completing within StartTimeout, or a Terminate hook not
+// returning within TerminateTimeout.
+func IsTimeout(err error) bool {
+	return errors.Is(err, errTimeout)
+}
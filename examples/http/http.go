@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -11,7 +12,9 @@ import (
 type MyHTTPServer struct {
 	*lifecycle.Worker
 
-	server *http.Server
+	addr    string
+	handler http.Handler
+	server  *http.Server
 }
 
 // NewHTTPServer creates a new HTTP server.
@@ -20,23 +23,35 @@ func NewHTTPServer(addr string) *MyHTTPServer {
 	mux.HandleFunc("/", func(rw http.ResponseWriter, req *http.Request) {
 		rw.Write([]byte("Hello!"))
 	})
-	server := http.Server{Addr: addr, Handler: mux}
-	return &MyHTTPServer{
-		Worker: lifecycle.NewWorkerWithOptions(&lifecycle.Hooks{
-			Start:     lifecycle.DropContext(server.ListenAndServe),
-			Shutdown:  server.Shutdown,
-			Terminate: lifecycle.DropContext(server.Close),
-			Error: func(event lifecycle.Event) error {
-				if event.Error == http.ErrServerClosed {
-					return nil
-				}
-				return event.Error
-			},
-		}, &lifecycle.ServiceOptions{
-			Logger: simpleLogger{},
-		}),
-		server: &server,
-	}
+	s := &MyHTTPServer{addr: addr, handler: mux}
+	s.Worker = lifecycle.NewWorkerWithOptions(&lifecycle.Hooks{
+		PreStart: s.preStart,
+		Start: func(ctx context.Context) error {
+			return s.server.ListenAndServe()
+		},
+		Shutdown: func(ctx context.Context) error {
+			return s.server.Shutdown(ctx)
+		},
+		Terminate: func(ctx context.Context) error {
+			return s.server.Close()
+		},
+		Error: func(event lifecycle.Event) error {
+			if event.Error == http.ErrServerClosed {
+				return nil
+			}
+			return event.Error
+		},
+	}, &lifecycle.ServiceOptions{
+		Logger: simpleLogger{},
+	})
+	return s
+}
+
+// preStart creates the http.Server of the current run: a closed server cannot
+// be started again, so a new one is needed once the service is reset.
+func (s *MyHTTPServer) preStart(ctx context.Context) error {
+	s.server = &http.Server{Addr: s.addr, Handler: s.handler}
+	return nil
 }
 
 func main() {
@@ -0,0 +1,100 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HealthHandler returns an http.Handler serving Kubernetes-style health
+// endpoints for the provided services. The /livez endpoint succeeds while none
+// of the services is stopped or in an Error state, the /readyz endpoint
+// succeeds once all the services are ready and either Started or Reloading,
+// and the /healthz endpoint succeeds when both of them succeed.
+//
+// Failing checks are reported with a 503 status code. When the verbose query
+// parameter is provided (for example /readyz?verbose), the response is a JSON
+// document listing the state and last error of each service.
+func HealthHandler(services ...Service) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/livez", &healthHandler{services, isLive})
+	mux.Handle("/readyz", &healthHandler{services, isReady})
+	mux.Handle("/healthz", &healthHandler{services, func(s Service) bool {
+		return isLive(s) && isReady(s)
+	}})
+	return mux
+}
+
+// healthHandler serves a single health endpoint.
+type healthHandler struct {
+	services []Service
+	check    func(s Service) bool
+}
+
+// healthStatus is the verbose response of a health endpoint.
+type healthStatus struct {
+	Status   string          `json:"status"`
+	Services []serviceStatus `json:"services"`
+}
+
+// serviceStatus is the status of a single service in a verbose response.
+type serviceStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	State  string `json:"state"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (h *healthHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	res := healthStatus{
+		Status:   "ok",
+		Services: make([]serviceStatus, len(h.services)),
+	}
+	for i, s := range h.services {
+		status := serviceStatus{
+			Name:   s.Name(),
+			Status: "ok",
+			State:  s.State().String(),
+		}
+		if err := s.Err(); err != nil {
+			status.Error = err.Error()
+		}
+		if !h.check(s) {
+			status.Status = "failed"
+			res.Status = "failed"
+		}
+		res.Services[i] = status
+	}
+
+	code := http.StatusOK
+	if res.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+	rw.Header().Set("Cache-Control", "no-cache")
+	if _, verbose := req.URL.Query()["verbose"]; verbose {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(code)
+		json.NewEncoder(rw).Encode(res)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.WriteHeader(code)
+	rw.Write([]byte(res.Status))
+}
+
+// isLive returns true if the service is neither stopped nor in an Error state.
+func isLive(s Service) bool {
+	state := s.State()
+	return state != Stopped && state != Error
+}
+
+// isReady returns true if the service is ready and Started. A service being
+// reloaded keeps serving, and is also reported as ready.
+func isReady(s Service) bool {
+	select {
+	case <-s.Ready():
+		state := s.State()
+		return state == Started || state == Reloading
+	default:
+		return false
+	}
+}
@@ -0,0 +1,57 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandler(t *testing.T) {
+	s1 := newTestWorker("s1", 0, time.Second, nil)
+	s2 := newTestWorker("s2", 0, time.Second, nil)
+	handler := HealthHandler(s1, s2)
+	get := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, path, nil))
+		return rw
+	}
+
+	assert.Equal(t, http.StatusOK, get("/livez").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, get("/readyz").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, get("/healthz").Code)
+
+	assert.NoError(t, s1.doStart())
+	assert.NoError(t, s2.doStart())
+	rw := get("/readyz")
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "ok", rw.Body.String())
+	assert.Equal(t, http.StatusOK, get("/healthz").Code)
+
+	// Services being reloaded are ready
+	s2.transition(context.Background(), Reloading, []State{Started}, nil)
+	assert.Equal(t, http.StatusOK, get("/readyz").Code)
+	s2.transition(context.Background(), Started, []State{Reloading}, nil)
+
+	s2.interrupt(errors.New("oops"))
+	<-s2.Done()
+	assert.Equal(t, http.StatusServiceUnavailable, get("/livez").Code)
+	rw = get("/healthz?verbose")
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	var status healthStatus
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &status))
+	assert.Equal(t, healthStatus{
+		Status: "failed",
+		Services: []serviceStatus{
+			{Name: "s1", Status: "ok", State: "Started"},
+			{Name: "s2", Status: "failed", State: "Error", Error: "oops"},
+		},
+	}, status)
+	assert.NoError(t, s1.Shutdown())
+}
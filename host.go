@@ -0,0 +1,463 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Host is a Service wrapping multiple services into a single startable unit.
+// Starting the host starts all of its services, and the host becomes ready
+// once all of them are ready. When one of the services fails, or when the host
+// is shut down or terminated (for example upon receiving a signal), all the
+// services are shut down or terminated as well.
+//
+// Dependencies between services can be declared with DependsOn. A service is
+// then only started once all its dependencies are ready, and is shut down
+// before any of them.
+//
+// Services managed by a host do not listen to signals themselves, whatever
+// their options: signals are handled by the host, which shuts down or
+// terminates its services in dependency order.
+type Host struct {
+	*Worker
+	// Managed services
+	services []Service
+	// Dependencies of the managed services
+	deps map[Service][]Service
+	// Protects errs, and failed and stopping when the host is reset
+	errMut sync.Mutex
+	// Errors reported by the managed services
+	errs *multierror.Error
+	// Closed when one of the managed services failed
+	failed chan struct{}
+	// Prevent against double close of the failed chan
+	failOnce sync.Once
+	// Closed when the host is shut down or terminated
+	stopping chan struct{}
+	// Prevent against double close of the stopping chan
+	stopOnce sync.Once
+}
+
+// NewHost creates a Host managing the provided services.
+func NewHost(name string, services ...Service) *Host {
+	return NewHostWithOptions(name, nil, services...)
+}
+
+// NewHostWithOptions creates a Host managing the provided services with the
+// provided options. If a readiness probe is provided, it is executed once all
+// the managed services are ready.
+func NewHostWithOptions(name string, opts *ServiceOptions,
+	services ...Service) *Host {
+	if opts == nil {
+		opts = &ServiceOptions{}
+	}
+	opts = opts.copy()
+	h := &Host{
+		services: services,
+		deps:     make(map[Service][]Service),
+		failed:   make(chan struct{}),
+		stopping: make(chan struct{}),
+	}
+	probe := opts.readinessProbe()
+	opts.ReadinessProbe = nil
+	opts.ReadinessProbeCtx = func(ctx context.Context) <-chan error {
+		return h.probe(ctx, probe)
+	}
+	h.Worker = NewWorkerWithOptions(&Hooks{
+		Name:      name,
+		Start:     h.start,
+		Shutdown:  h.shutdown,
+		Terminate: h.terminate,
+	}, opts)
+	return h
+}
+
+// Services returns the services managed by the host.
+func (h *Host) Services() []Service {
+	return h.services
+}
+
+// Reset re-arms the host and all its services, so that they can be started
+// again. See Worker.Reset.
+func (h *Host) Reset() error {
+	return h.ResetCtx(context.Background())
+}
+
+// ResetCtx re-arms the host and all its services providing context. See
+// Worker.ResetCtx.
+func (h *Host) ResetCtx(ctx context.Context) error {
+	if err := h.Worker.ResetCtx(ctx); err != nil {
+		return err
+	}
+	var result *multierror.Error
+	for _, s := range h.services {
+		if s.State() == Initial {
+			continue
+		}
+		if err := s.ResetCtx(ctx); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	h.errMut.Lock()
+	defer h.errMut.Unlock()
+	h.errs = nil
+	h.failed = make(chan struct{})
+	h.failOnce = sync.Once{}
+	h.stopping = make(chan struct{})
+	h.stopOnce = sync.Once{}
+	return result.ErrorOrNil()
+}
+
+// DependsOn declares that the service s depends on the provided services: s
+// is started once all of them are ready, and shut down before any of them is.
+// Both s and its dependencies must be managed by the host. Missing
+// dependencies and dependency cycles are reported by StartBackgroundCtx,
+// before anything is started.
+func (h *Host) DependsOn(s Service, deps ...Service) {
+	h.deps[s] = append(h.deps[s], deps...)
+}
+
+// Start starts the host and blocks until it is stopped. See Worker.Start.
+func (h *Host) Start() error {
+	return h.StartCtx(context.Background())
+}
+
+// StartCtx starts the host providing context, and blocks until it is stopped.
+// See Worker.StartCtx.
+func (h *Host) StartCtx(ctx context.Context) error {
+	if err := h.StartBackgroundCtx(ctx); err != nil {
+		return err
+	}
+	<-h.Done()
+	return nil
+}
+
+// StartBackground starts the host in the background. See
+// Worker.StartBackground.
+func (h *Host) StartBackground() error {
+	return h.StartBackgroundCtx(context.Background())
+}
+
+// StartBackgroundCtx starts the host in the background providing context. It
+// returns an invalid dependency error, without starting anything, if the
+// declared dependencies reference services that are not managed by the host or
+// contain a cycle. See Worker.StartBackgroundCtx.
+func (h *Host) StartBackgroundCtx(ctx context.Context) error {
+	if err := h.validate(); err != nil {
+		return err
+	}
+	return h.Worker.StartBackgroundCtx(ctx)
+}
+
+// start starts all the managed services and blocks until all of them are
+// done. If any of the services fails, the other ones are shut down.
+func (h *Host) start(ctx context.Context) error {
+	for _, s := range h.services {
+		if state := s.State(); state != Initial {
+			return fmt.Errorf("cannot start service %s from %s: %w", s.Name(),
+				state.String(), errInvalidState)
+		}
+	}
+
+	// Managed services are shut down by the host in dependency order, so they
+	// must neither be cancelled along with the run context of the host nor
+	// handle signals themselves.
+	ctx = withManaged(detach(ctx))
+
+	h.errMut.Lock()
+	failed, stopping := h.failed, h.stopping
+	h.errMut.Unlock()
+
+	// Services are started by goroutines which must return before the host
+	// can be reset.
+	var wg, starters sync.WaitGroup
+	defer starters.Wait()
+	for _, s := range h.services {
+		// Observe the service to collect its errors ; the chan is closed
+		// when the service reaches a final state.
+		events := make(chan Event)
+		s.Observe(events)
+		wg.Add(1)
+		go func(s Service) {
+			defer wg.Done()
+			for event := range events {
+				if event.To == Error && event.Error != nil {
+					h.fail(fmt.Errorf("service %s: %w", s.Name(), event.Error))
+				}
+			}
+		}(s)
+
+		// Start the service once its dependencies are ready ; errors are
+		// reported by the observer above.
+		starters.Add(1)
+		go func(s Service, events chan Event) {
+			defer starters.Done()
+			if !h.awaitDeps(s, failed, stopping) || !running(failed, stopping) {
+				// Do not start services once the host is stopping or another
+				// service failed
+				s.Unobserve(events)
+				close(events)
+				return
+			}
+			if err := s.StartBackgroundCtx(ctx); err != nil {
+				// The service did not transition, so its observer must be
+				// released here.
+				if IsInvalidState(err) {
+					s.Unobserve(events)
+					close(events)
+					h.fail(fmt.Errorf("service %s: %w", s.Name(), err))
+				}
+				return
+			}
+			if !running(failed, stopping) {
+				// The host started stopping or another service failed while
+				// this one was starting
+				err := s.ShutdownCtx(context.Background())
+				if err != nil && !IsInvalidState(err) {
+					h.fail(err)
+				}
+			}
+		}(s, events)
+	}
+
+	// Wait for all the services to be done, or for one of them to fail
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-failed:
+		h.info("service failed -- shutting down services")
+		if err := h.shutdown(context.Background()); err != nil {
+			h.fail(err)
+		}
+		<-done
+	}
+
+	h.errMut.Lock()
+	defer h.errMut.Unlock()
+	return h.errs.ErrorOrNil()
+}
+
+// shutdown gracefully shuts all the managed services down, in the reverse
+// order of their dependencies.
+func (h *Host) shutdown(ctx context.Context) error {
+	h.stop()
+	var dependents map[Service][]Service
+	if h.validate() == nil {
+		dependents = h.dependents()
+	}
+	stopped := make(map[Service]chan struct{}, len(h.services))
+	for _, s := range h.services {
+		stopped[s] = make(chan struct{})
+	}
+	return h.each(func(s Service) error {
+		defer close(stopped[s])
+		for _, dependent := range dependents[s] {
+			<-stopped[dependent]
+		}
+		return s.ShutdownCtx(ctx)
+	})
+}
+
+// terminate terminates all the managed services.
+func (h *Host) terminate(ctx context.Context) error {
+	h.stop()
+	return h.each(func(s Service) error {
+		return s.TerminateCtx(ctx)
+	})
+}
+
+// awaitDeps blocks until the dependencies of s are ready. It returns false if
+// the host is stopping, if another service failed, or if a dependency stopped
+// without being started, in which case s must not be started.
+func (h *Host) awaitDeps(s Service, failed, stopping <-chan struct{}) bool {
+	for _, dep := range h.deps[s] {
+		select {
+		case <-dep.Ready():
+		case <-dep.Done():
+		case <-failed:
+			return false
+		case <-stopping:
+			return false
+		}
+		switch state := dep.State(); state {
+		case Error:
+			// Reported by the observer of the dependency
+			return false
+		case ShuttingDown, Terminating, Stopped:
+			if running(failed, stopping) {
+				h.fail(fmt.Errorf("dependency %s of service %s is %s",
+					dep.Name(), s.Name(), state.String()))
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// each concurrently calls fn for every managed service and aggregates the
+// returned errors. Invalid state errors are ignored, as they are expected for
+// services that are already stopped.
+func (h *Host) each(fn func(s Service) error) error {
+	var (
+		wg     sync.WaitGroup
+		mut    sync.Mutex
+		result *multierror.Error
+	)
+	for _, s := range h.services {
+		wg.Add(1)
+		go func(s Service) {
+			defer wg.Done()
+			if err := fn(s); err != nil && !IsInvalidState(err) {
+				mut.Lock()
+				defer mut.Unlock()
+				result = multierror.Append(result, err)
+			}
+		}(s)
+	}
+	wg.Wait()
+	return result.ErrorOrNil()
+}
+
+// dependents returns the reverse dependency map of the managed services.
+func (h *Host) dependents() map[Service][]Service {
+	dependents := make(map[Service][]Service, len(h.services))
+	for _, s := range h.services {
+		for _, dep := range h.deps[s] {
+			dependents[dep] = append(dependents[dep], s)
+		}
+	}
+	return dependents
+}
+
+// validate checks that all the declared dependencies are managed by the host
+// and that they do not contain any cycle.
+func (h *Host) validate() error {
+	managed := make(map[Service]bool, len(h.services))
+	for _, s := range h.services {
+		managed[s] = true
+	}
+	for s, deps := range h.deps {
+		if !managed[s] {
+			return fmt.Errorf("service %s is not managed by the host: %w",
+				s.Name(), errDependency)
+		}
+		for _, dep := range deps {
+			if !managed[dep] {
+				return fmt.Errorf("dependency %s of service %s is not managed "+
+					"by the host: %w", dep.Name(), s.Name(), errDependency)
+			}
+		}
+	}
+
+	// Depth-first search of the dependency graph, failing when a service
+	// currently being visited is reached again.
+	const (
+		visiting = iota + 1
+		visited
+	)
+	marks := make(map[Service]int, len(h.services))
+	var visit func(s Service, path []string) error
+	visit = func(s Service, path []string) error {
+		path = append(path, s.Name())
+		switch marks[s] {
+		case visiting:
+			return fmt.Errorf("dependency cycle %s: %w",
+				strings.Join(path, " -> "), errDependency)
+		case visited:
+			return nil
+		}
+		marks[s] = visiting
+		for _, dep := range h.deps[s] {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		marks[s] = visited
+		return nil
+	}
+	for _, s := range h.services {
+		if err := visit(s, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// probe returns a chan that is closed once all the managed services are ready
+// and the next probe, if any, succeeded.
+func (h *Host) probe(ctx context.Context,
+	next func(ctx context.Context) <-chan error) <-chan error {
+	h.errMut.Lock()
+	failed := h.failed
+	h.errMut.Unlock()
+	ch := make(chan error, 1)
+	go func() {
+		defer close(ch)
+		for _, s := range h.services {
+			select {
+			case <-s.Ready():
+			case <-failed:
+				h.errMut.Lock()
+				err := h.errs.ErrorOrNil()
+				h.errMut.Unlock()
+				ch <- fmt.Errorf("service failed while starting: %w", err)
+				return
+			case <-ctx.Done():
+				ch <- ctx.Err()
+				return
+			}
+		}
+		if next != nil {
+			if err := <-next(ctx); err != nil {
+				ch <- err
+			}
+		}
+	}()
+	return ch
+}
+
+// fail records an error returned by a managed service and notifies the host
+// that a service failed.
+func (h *Host) fail(err error) {
+	h.errMut.Lock()
+	h.errs = multierror.Append(h.errs, err)
+	h.errMut.Unlock()
+	h.abort()
+}
+
+// abort notifies the host that a service failed.
+func (h *Host) abort() {
+	h.failOnce.Do(func() {
+		close(h.failed)
+	})
+}
+
+// stop notifies the host that it is being shut down or terminated, so that the
+// services which are not started yet are not started anymore.
+func (h *Host) stop() {
+	h.errMut.Lock()
+	defer h.errMut.Unlock()
+	h.stopOnce.Do(func() {
+		close(h.stopping)
+	})
+}
+
+// running returns false once the host is stopping or a service failed.
+func running(failed, stopping <-chan struct{}) bool {
+	select {
+	case <-failed:
+		return false
+	case <-stopping:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,213 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostShutdown(t *testing.T) {
+	s1 := newTestWorker("s1", 0, time.Second, nil)
+	s2 := newTestWorker("s2", 0, time.Second, nil)
+	h := newTestHost(s1, s2)
+	assert.NoError(t, h.StartBackground())
+	<-h.Ready()
+	assert.Equal(t, Started, h.State())
+	assert.Equal(t, Started, s1.State())
+	assert.Equal(t, Started, s2.State())
+	assert.NoError(t, h.Shutdown())
+	<-h.Done()
+	assert.Equal(t, Stopped, h.State())
+	assert.Equal(t, []State{Starting, Started, ShuttingDown, Stopped},
+		s1.ObserverEventSequence())
+	assert.Equal(t, []State{Starting, Started, ShuttingDown, Stopped},
+		s2.ObserverEventSequence())
+}
+
+func TestHostTerminate(t *testing.T) {
+	s1 := newTestWorker("s1", 0, time.Second, nil)
+	s2 := newTestWorker("s2", 0, time.Second, nil)
+	h := newTestHost(s1, s2)
+	assert.NoError(t, h.StartBackground())
+	<-h.Ready()
+	assert.NoError(t, h.Terminate())
+	<-h.Done()
+	assert.Equal(t, []State{Starting, Started, Terminating, Stopped},
+		s1.ObserverEventSequence())
+	assert.Equal(t, []State{Starting, Started, Terminating, Stopped},
+		s2.ObserverEventSequence())
+}
+
+func TestHostServiceError(t *testing.T) {
+	s1 := newTestWorker("s1", 0, time.Second, nil)
+	s2 := newTestWorker("s2", 0, time.Second, nil)
+	h := newTestHost(s1, s2)
+	assert.NoError(t, h.StartBackground())
+	<-h.Ready()
+	s1.interrupt(errors.New("oops"))
+	<-h.Done()
+	assert.Equal(t, Error, h.State())
+	assert.Equal(t, []State{Starting, Started, Error},
+		s1.ObserverEventSequence())
+	assert.Equal(t, []State{Starting, Started, ShuttingDown, Stopped},
+		s2.ObserverEventSequence())
+}
+
+func TestHostReadinessProbeError(t *testing.T) {
+	s1 := newTestWorker("s1", 0, time.Second, nil)
+	s2 := newTestWorker("s2", 0, time.Second, func() <-chan error {
+		ch := make(chan error, 1)
+		ch <- errors.New("oops")
+		return ch
+	})
+	h := newTestHost(s1, s2)
+	err := h.StartBackground()
+	assert.False(t, IsInterrupted(err))
+	assert.Contains(t, err.Error(), "service s2: oops")
+	<-h.Done()
+	assert.Equal(t, Error, h.State())
+	assert.Equal(t, []State{Starting, Error}, s2.ObserverEventSequence())
+	// The host is done as soon as it fails, before its services are stopped
+	<-s1.Done()
+	assert.NotEqual(t, Started, s1.State())
+}
+
+func TestHostDependencies(t *testing.T) {
+	var (
+		mut sync.Mutex
+		log []string
+	)
+	record := func(msg string) {
+		mut.Lock()
+		defer mut.Unlock()
+		log = append(log, msg)
+	}
+	newService := func(name string) Service {
+		stop := make(chan struct{})
+		return NewWorkerWithOptions(&Hooks{
+			Name: name,
+			Start: func(ctx context.Context) error {
+				<-stop
+				return nil
+			},
+			Shutdown: func(ctx context.Context) error {
+				record("stop " + name)
+				close(stop)
+				return nil
+			},
+		}, &ServiceOptions{
+			ReadinessProbe: func() <-chan error {
+				record("ready " + name)
+				return Wait(5 * time.Millisecond)()
+			},
+			Signals: []os.Signal{},
+		})
+	}
+	db, cache, http := newService("db"), newService("cache"),
+		newService("http")
+	h := newTestHost(http, cache, db)
+	h.DependsOn(http, db, cache)
+	h.DependsOn(cache, db)
+	assert.NoError(t, h.StartBackground())
+	<-h.Ready()
+	assert.NoError(t, h.Shutdown())
+	<-h.Done()
+	assert.Equal(t, Stopped, h.State())
+	assert.Equal(t, []string{"ready db", "ready cache", "ready http",
+		"stop http", "stop cache", "stop db"}, log)
+}
+
+func TestHostShutdownWhileStarting(t *testing.T) {
+	db := newTestWorker("db", 0, time.Second, func() <-chan error {
+		return make(chan error)
+	})
+	api := newTestWorker("api", 0, time.Second, nil)
+	h := newTestHost(api, db)
+	h.DependsOn(api, db)
+	go h.StartBackground()
+	assert.Eventually(t, func() bool {
+		return db.State() == Starting
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, h.Shutdown())
+	select {
+	case <-h.Done():
+	case <-time.After(time.Second):
+		t.Fatal("host not done")
+	}
+	assert.Equal(t, Stopped, db.State())
+	assert.Equal(t, Initial, api.State())
+}
+
+func TestHostSignals(t *testing.T) {
+	router := NewSignalRouter()
+	s := NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			return nil
+		},
+	}, &ServiceOptions{SignalRouter: router})
+	h := NewHostWithOptions("host", &ServiceOptions{SignalRouter: router}, s)
+	assert.NoError(t, h.StartBackground())
+	router.mut.Lock()
+	assert.Len(t, router.routes, 1)
+	router.mut.Unlock()
+	router.Dispatch(syscall.SIGTERM)
+	<-h.Done()
+	assert.Equal(t, Stopped, h.State())
+	assert.Equal(t, Stopped, s.State())
+}
+
+func TestHostInvalidDependencies(t *testing.T) {
+	s1 := newTestWorker("s1", 0, time.Second, nil)
+	s2 := newTestWorker("s2", 0, time.Second, nil)
+	s3 := newTestWorker("s3", 0, time.Second, nil)
+
+	h := newTestHost(s1, s2)
+	h.DependsOn(s1, s3)
+	assert.True(t, IsInvalidDependency(h.validate()))
+
+	h = newTestHost(s1, s2, s3)
+	h.DependsOn(s1, s2)
+	h.DependsOn(s2, s3)
+	h.DependsOn(s3, s1)
+	err := h.validate()
+	assert.True(t, IsInvalidDependency(err))
+	assert.Contains(t, err.Error(), "s1 -> s2 -> s3 -> s1")
+
+	assert.True(t, IsInvalidDependency(h.StartBackground()))
+	assert.True(t, IsInvalidDependency(h.Start()))
+	assert.Equal(t, Initial, h.State())
+	assert.Equal(t, Initial, s1.State())
+	assert.Equal(t, Initial, s2.State())
+	assert.Equal(t, Initial, s3.State())
+}
+
+func TestHostReset(t *testing.T) {
+	s1 := newTestWorker("s1", 0, time.Second, nil)
+	h := newTestHost(s1)
+	assert.NoError(t, h.StartBackground())
+	<-h.Ready()
+	assert.NoError(t, h.Shutdown())
+	<-h.Done()
+	assert.NoError(t, h.Reset())
+	assert.Equal(t, Initial, h.State())
+	assert.Equal(t, Initial, s1.State())
+}
+
+func newTestHost(services ...Service) *Host {
+	return NewHostWithOptions("host", &ServiceOptions{
+		ShutdownTimeout: time.Second,
+		Logger:          simpleLogger{},
+		Signals:         []os.Signal{},
+	}, services...)
+}
@@ -0,0 +1,229 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Lifecycle is a Service built from a registry of OnStart and OnStop hooks,
+// which components append while the application is being wired. Starting the
+// lifecycle calls the OnStart hooks in the order in which they were appended,
+// and the service becomes ready once all of them returned. Shutting it down
+// calls the OnStop hooks in reverse order.
+//
+// If an OnStart hook fails, only the OnStop hooks of the components which
+// already started are called, in reverse order, and the lifecycle transitions
+// to an Error state. Terminating the lifecycle calls the OnStop hooks as well,
+// unless they are already being called, but stops waiting for them once the
+// context of the termination is done, for example after a shutdown timeout.
+type Lifecycle struct {
+	*Worker
+	// Protects hooks, stopCtx and stopErr
+	lifecycleMut sync.Mutex
+	// Registered hooks, in registration order
+	hooks []lifecycleHook
+	// Context provided to the OnStop hooks, set when a stop is requested
+	stopCtx context.Context
+	// Closed when a stop is requested
+	stopping chan struct{}
+	// Errors returned by the OnStop hooks
+	stopErr error
+	// Closed once the OnStop hooks returned
+	stopped chan struct{}
+}
+
+// lifecycleHook is a pair of hooks appended to a Lifecycle.
+type lifecycleHook struct {
+	// Called when the lifecycle is started
+	onStart ContextHook
+	// Called when the lifecycle is stopped, if onStart succeeded
+	onStop ContextHook
+}
+
+// NewLifecycle creates an empty Lifecycle.
+func NewLifecycle(name string) *Lifecycle {
+	return NewLifecycleWithOptions(name, nil)
+}
+
+// NewLifecycleWithOptions creates an empty Lifecycle with the provided
+// options. If a readiness probe is provided, it is executed once all the
+// OnStart hooks returned.
+func NewLifecycleWithOptions(name string, opts *ServiceOptions) *Lifecycle {
+	if opts == nil {
+		opts = &ServiceOptions{}
+	}
+	opts = opts.copy()
+	opts.ReadyNotify = true
+	l := &Lifecycle{
+		stopping: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	l.Worker = NewWorkerWithOptions(&Hooks{
+		Name:      name,
+		Start:     l.start,
+		Shutdown:  l.shutdown,
+		Terminate: l.terminate,
+	}, opts)
+	return l
+}
+
+// Append registers a pair of hooks, either of which can be nil. The OnStart
+// hook receives the run context of the service, see Worker.Context, while the
+// OnStop hook receives the context provided to the shutdown. The OnStop hook
+// is only called if the OnStart hook succeeded. Hooks appended once the
+// lifecycle is started are only taken into account by the next start.
+func (l *Lifecycle) Append(onStart ContextHook, onStop ContextHook) {
+	l.lifecycleMut.Lock()
+	defer l.lifecycleMut.Unlock()
+	l.hooks = append(l.hooks, lifecycleHook{onStart: onStart, onStop: onStop})
+}
+
+// Reset re-arms the lifecycle, so that it can be started again. See
+// Worker.Reset.
+func (l *Lifecycle) Reset() error {
+	return l.ResetCtx(context.Background())
+}
+
+// ResetCtx re-arms the lifecycle providing context. See Worker.ResetCtx.
+func (l *Lifecycle) ResetCtx(ctx context.Context) error {
+	if err := l.Worker.ResetCtx(ctx); err != nil {
+		return err
+	}
+	l.lifecycleMut.Lock()
+	defer l.lifecycleMut.Unlock()
+	l.stopCtx = nil
+	l.stopping = make(chan struct{})
+	l.stopErr = nil
+	l.stopped = make(chan struct{})
+	return nil
+}
+
+// start calls the OnStart hooks in order, then blocks until a stop is
+// requested and calls the OnStop hooks of the started components in reverse
+// order. When an OnStart hook fails, the components which already started are
+// stopped and the error is returned, along with the errors of the OnStop
+// hooks.
+func (l *Lifecycle) start(ctx context.Context) error {
+	l.lifecycleMut.Lock()
+	hooks := append([]lifecycleHook(nil), l.hooks...)
+	stopping, stopped := l.stopping, l.stopped
+	l.lifecycleMut.Unlock()
+	defer close(stopped)
+
+	started := 0
+	var err error
+	for i, hook := range hooks {
+		if isClosed(stopping) {
+			break
+		}
+		if hook.onStart != nil {
+			if err = hook.onStart(ctx); err != nil {
+				// Errors caused by a stop interrupting the start are not
+				// reported.
+				if errors.Is(err, ctx.Err()) {
+					err = nil
+				} else {
+					err = fmt.Errorf("start hook %d failed: %w", i, err)
+				}
+				break
+			}
+		}
+		started++
+	}
+
+	// Roll back the started components if the start failed, or wait for a
+	// stop request. The service may also transition to an Error state
+	// without being stopped, in which case the components are stopped as
+	// well.
+	stopCtx := detach(ctx)
+	if err == nil {
+		if started == len(hooks) {
+			NotifyReady(ctx)
+		}
+		select {
+		case <-stopping:
+		case <-l.Done():
+		}
+		l.lifecycleMut.Lock()
+		if l.stopCtx != nil {
+			stopCtx = l.stopCtx
+		}
+		l.lifecycleMut.Unlock()
+	}
+
+	var result *multierror.Error
+	for i := started - 1; i >= 0; i-- {
+		if hooks[i].onStop == nil {
+			continue
+		}
+		if stopErr := hooks[i].onStop(stopCtx); stopErr != nil {
+			result = multierror.Append(result,
+				fmt.Errorf("stop hook %d failed: %w", i, stopErr))
+		}
+	}
+	if err != nil {
+		if result == nil {
+			return err
+		}
+		return multierror.Append(err, result.Errors...)
+	}
+	// Stop errors are reported by both hooks, so that the service ends up in
+	// an Error state whichever transitions first.
+	err = result.ErrorOrNil()
+	l.lifecycleMut.Lock()
+	l.stopErr = err
+	l.lifecycleMut.Unlock()
+	return err
+}
+
+// shutdown requests the OnStop hooks to be called, and waits for them to
+// return.
+func (l *Lifecycle) shutdown(ctx context.Context) error {
+	stopped := l.stop(ctx)
+	select {
+	case <-stopped:
+		l.lifecycleMut.Lock()
+		defer l.lifecycleMut.Unlock()
+		return l.stopErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// terminate requests the OnStop hooks to be called, unless a stop was already
+// requested, and returns without waiting for them once ctx is done.
+func (l *Lifecycle) terminate(ctx context.Context) error {
+	stopped := l.stop(ctx)
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+// stop requests the OnStop hooks to be called with the provided context, unless
+// a stop was already requested, and returns the chan closed once they
+// returned.
+func (l *Lifecycle) stop(ctx context.Context) <-chan struct{} {
+	l.lifecycleMut.Lock()
+	defer l.lifecycleMut.Unlock()
+	if l.stopCtx == nil {
+		l.stopCtx = ctx
+		close(l.stopping)
+	}
+	return l.stopped
+}
+
+// isClosed returns true if the provided chan is closed.
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,90 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycle(t *testing.T) {
+	l, log := newTestLifecycle(-1, -1)
+	assert.NoError(t, l.StartBackground())
+	assert.Equal(t, Started, l.State())
+	assert.NoError(t, l.Shutdown())
+	<-l.Done()
+	assert.Equal(t, Stopped, l.State())
+	assert.Equal(t, []string{"start 0", "start 1", "start 2",
+		"stop 2", "stop 1", "stop 0"}, log())
+}
+
+func TestLifecycleStartError(t *testing.T) {
+	l, log := newTestLifecycle(2, -1)
+	l.StartBackground()
+	<-l.Done()
+	assert.Equal(t, Error, l.State())
+	assert.Contains(t, l.Err().Error(), "start hook 2 failed: oops")
+	assert.Equal(t, []string{"start 0", "start 1", "start 2",
+		"stop 1", "stop 0"}, log())
+}
+
+func TestLifecycleStopError(t *testing.T) {
+	l, log := newTestLifecycle(-1, 1)
+	assert.NoError(t, l.StartBackground())
+	err := l.Shutdown()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "stop hook 1 failed: oops")
+	<-l.Done()
+	assert.Equal(t, Error, l.State())
+	assert.Equal(t, []string{"start 0", "start 1", "start 2",
+		"stop 2", "stop 1", "stop 0"}, log())
+}
+
+func TestLifecycleReset(t *testing.T) {
+	l, log := newTestLifecycle(-1, -1)
+	assert.NoError(t, l.StartBackground())
+	assert.NoError(t, l.Terminate())
+	<-l.Done()
+	assert.NoError(t, l.Reset())
+	assert.NoError(t, l.StartBackground())
+	assert.NoError(t, l.Shutdown())
+	assert.Len(t, log(), 12)
+}
+
+// newTestLifecycle creates a lifecycle with 3 components, whose hooks fail at
+// the provided indexes (-1 for none).
+func newTestLifecycle(startErr, stopErr int) (*Lifecycle, func() []string) {
+	var (
+		mut sync.Mutex
+		log []string
+	)
+	record := func(msg string, i, failAt int) error {
+		mut.Lock()
+		defer mut.Unlock()
+		log = append(log, msg+" "+string(rune('0'+i)))
+		if i == failAt {
+			return errors.New("oops")
+		}
+		return nil
+	}
+	l := NewLifecycleWithOptions("lifecycle", &ServiceOptions{
+		Logger:  simpleLogger{},
+		Signals: []os.Signal{},
+	})
+	for i := 0; i < 3; i++ {
+		i := i
+		l.Append(func(ctx context.Context) error {
+			return record("start", i, startErr)
+		}, func(ctx context.Context) error {
+			return record("stop", i, stopErr)
+		})
+	}
+	return l, func() []string {
+		mut.Lock()
+		defer mut.Unlock()
+		return append([]string(nil), log...)
+	}
+}
@@ -0,0 +1,91 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LivenessProbe defines a check periodically executed while a service is
+// Started. When the check fails FailureThreshold consecutive times, the action
+// defined by Action is taken.
+type LivenessProbe struct {
+	// Check performs a single liveness check. The provided context is
+	// cancelled after Timeout ; a check which does not return by then is
+	// abandoned and counts as a failure.
+	Check ContextHook
+	// Interval defines the amount of time between two checks (default: 10
+	// seconds).
+	Interval time.Duration
+	// Timeout defines the maximum duration of a single check (default: 1
+	// second).
+	Timeout time.Duration
+	// FailureThreshold defines the number of consecutive failures after which
+	// the action is taken (default: 3).
+	FailureThreshold int
+	// Action defines the action to be taken when the failure threshold is
+	// reached. Shutdown and Terminate respectively shut down and terminate the
+	// service, while DoNothing only posts an event containing the failure to
+	// the observers (default: Shutdown).
+	Action Action
+}
+
+func (p LivenessProbe) copy() *LivenessProbe {
+	return &p
+}
+
+// checkLiveness periodically executes the liveness probe until the provided
+// done chan is closed. Checks are skipped while the service is not Started.
+// The context provided to the checks derives from ctx, the run context of the
+// service.
+func (c *Worker) checkLiveness(ctx context.Context, done <-chan struct{}) {
+	probe := c.opts.LivenessProbe
+	ticker := time.NewTicker(probe.Interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+		if c.State() != Started {
+			continue
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, probe.Timeout)
+		err := callWithTimeout(checkCtx, probe.Check, probe.Timeout)
+		cancel()
+		if err == nil {
+			failures = 0
+			continue
+		}
+		failures++
+		c.error(err, "liveness check failed", "failures", failures)
+		if failures < probe.FailureThreshold {
+			continue
+		}
+
+		// Notify observers and take action
+		failures = 0
+		c.notify(ctx, fmt.Errorf("liveness probe failed %d times: %w",
+			probe.FailureThreshold, err))
+		// Invalid state errors are ignored, as they are expected when the
+		// service is already being stopped.
+		switch probe.Action {
+		case Shutdown:
+			c.info("service is not alive -- shutting down")
+			err = c.Shutdown()
+		case Terminate:
+			c.info("service is not alive -- terminating")
+			err = c.Terminate()
+		default:
+			continue
+		}
+		if !IsInvalidState(err) {
+			c.handleError(ctx, err)
+		}
+		return
+	}
+}
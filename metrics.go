@@ -0,0 +1,245 @@
+package lifecycle
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the duration
+// histograms buckets.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1,
+	2.5, 5, 10, 15, 30, 60}
+
+// MetricsHandler returns an http.Handler writing metrics about the provided
+// workers in the Prometheus text exposition format. The following metrics are
+// exposed, labeled by service name:
+//
+//     lifecycle_state                        current state (one series per state)
+//     lifecycle_transitions_total            state transitions by from/to states
+//     lifecycle_errors_total                 errors reported by the service
+//     lifecycle_dropped_events_total         events dropped by observers
+//     lifecycle_shutdown_escalations_total   shutdowns terminated after ShutdownTimeout
+//     lifecycle_startup_duration_seconds     time from Starting to Started
+//     lifecycle_shutdown_duration_seconds    time from ShuttingDown to Stopped
+//
+// Events are collected from the moment the handler is created. Workers should
+// have distinct names, as series are identified by service name.
+func MetricsHandler(workers ...*Worker) http.Handler {
+	h := &metricsHandler{}
+	for _, w := range workers {
+		m := &workerMetrics{
+			worker:      w,
+			transitions: make(map[[2]State]uint64),
+			startup:     newHistogram(durationBuckets),
+			shutdown:    newHistogram(durationBuckets),
+		}
+		w.OnTransition(m.observe)
+		h.metrics = append(h.metrics, m)
+	}
+	return h
+}
+
+// metricsHandler serves the metrics of a set of workers.
+type metricsHandler struct {
+	metrics []*workerMetrics
+}
+
+func (h *metricsHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+
+	writeHeader(&buf, "lifecycle_state", "gauge",
+		"Current state of the service.")
+	for _, m := range h.metrics {
+		current := m.worker.State()
+		for _, state := range states {
+			value := 0
+			if state == current {
+				value = 1
+			}
+			fmt.Fprintf(&buf, "lifecycle_state{service=%s,state=%s} %d\n",
+				quoteLabel(m.worker.Name()), quoteLabel(state.String()), value)
+		}
+	}
+
+	writeHeader(&buf, "lifecycle_transitions_total", "counter",
+		"Number of state transitions of the service.")
+	for _, m := range h.metrics {
+		m.mut.Lock()
+		keys := make([][2]State, 0, len(m.transitions))
+		for key := range m.transitions {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i][0] != keys[j][0] {
+				return keys[i][0] < keys[j][0]
+			}
+			return keys[i][1] < keys[j][1]
+		})
+		for _, key := range keys {
+			fmt.Fprintf(&buf,
+				"lifecycle_transitions_total{service=%s,from=%s,to=%s} %d\n",
+				quoteLabel(m.worker.Name()), quoteLabel(key[0].String()),
+				quoteLabel(key[1].String()), m.transitions[key])
+		}
+		m.mut.Unlock()
+	}
+
+	writeHeader(&buf, "lifecycle_errors_total", "counter",
+		"Number of errors reported by the service.")
+	for _, m := range h.metrics {
+		m.mut.Lock()
+		fmt.Fprintf(&buf, "lifecycle_errors_total{service=%s} %d\n",
+			quoteLabel(m.worker.Name()), m.errors)
+		m.mut.Unlock()
+	}
+
+	writeHeader(&buf, "lifecycle_dropped_events_total", "counter",
+		"Number of events dropped by the observers of the service.")
+	for _, m := range h.metrics {
+		fmt.Fprintf(&buf, "lifecycle_dropped_events_total{service=%s} %d\n",
+			quoteLabel(m.worker.Name()), m.worker.DroppedEvents())
+	}
+
+	writeHeader(&buf, "lifecycle_shutdown_escalations_total", "counter",
+		"Number of graceful shutdowns terminated after the shutdown timeout.")
+	for _, m := range h.metrics {
+		m.mut.Lock()
+		fmt.Fprintf(&buf,
+			"lifecycle_shutdown_escalations_total{service=%s} %d\n",
+			quoteLabel(m.worker.Name()), m.escalations)
+		m.mut.Unlock()
+	}
+
+	writeHeader(&buf, "lifecycle_startup_duration_seconds", "histogram",
+		"Time spent by the service between Starting and Started.")
+	for _, m := range h.metrics {
+		m.mut.Lock()
+		m.startup.write(&buf, "lifecycle_startup_duration_seconds",
+			m.worker.Name())
+		m.mut.Unlock()
+	}
+
+	writeHeader(&buf, "lifecycle_shutdown_duration_seconds", "histogram",
+		"Time spent by the service between ShuttingDown and Stopped.")
+	for _, m := range h.metrics {
+		m.mut.Lock()
+		m.shutdown.write(&buf, "lifecycle_shutdown_duration_seconds",
+			m.worker.Name())
+		m.mut.Unlock()
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	rw.Write(buf.Bytes())
+}
+
+// workerMetrics collects the metrics of a worker from its events.
+type workerMetrics struct {
+	// Observed worker
+	worker *Worker
+	// Protects the fields below
+	mut sync.Mutex
+	// Number of transitions by from/to states
+	transitions map[[2]State]uint64
+	// Number of errors
+	errors uint64
+	// Number of shutdowns escalated to a termination
+	escalations uint64
+	// Startup durations
+	startup *histogram
+	// Shutdown durations
+	shutdown *histogram
+	// Time at which the current shutdown started, zero if none
+	shutdownStart time.Time
+}
+
+// observe updates the metrics with the provided event.
+func (m *workerMetrics) observe(event Event) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if event.From != event.To {
+		m.transitions[[2]State{event.From, event.To}]++
+	}
+	if event.Error != nil {
+		m.errors++
+	}
+	switch {
+	case event.From == Starting && event.To == Started:
+		m.startup.observe(event.Duration)
+	case event.To == Terminating && event.Reason == reasonShutdownTimeout:
+		m.escalations++
+	case event.To == ShuttingDown:
+		m.shutdownStart = event.Time
+	case event.To == Stopped && !m.shutdownStart.IsZero():
+		m.shutdown.observe(event.Time.Sub(m.shutdownStart))
+		m.shutdownStart = time.Time{}
+	case event.To == Error:
+		m.shutdownStart = time.Time{}
+	}
+}
+
+// histogram is a Prometheus-style histogram of durations.
+type histogram struct {
+	// Upper bounds of the buckets, in seconds
+	buckets []float64
+	// Number of observations per bucket (not cumulative)
+	counts []uint64
+	// Number of observations
+	count uint64
+	// Sum of the observations, in seconds
+	sum float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// observe adds an observation to the histogram.
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+	h.count++
+	h.sum += v
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+}
+
+// write writes the histogram series for the provided service.
+func (h *histogram) write(buf *bytes.Buffer, name string, service string) {
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(buf, "%s_bucket{service=%s,le=\"%g\"} %d\n", name,
+			quoteLabel(service), bound, cumulative)
+	}
+	fmt.Fprintf(buf, "%s_bucket{service=%s,le=\"+Inf\"} %d\n", name,
+		quoteLabel(service), h.count)
+	fmt.Fprintf(buf, "%s_sum{service=%s} %g\n", name, quoteLabel(service),
+		h.sum)
+	fmt.Fprintf(buf, "%s_count{service=%s} %d\n", name, quoteLabel(service),
+		h.count)
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric.
+func writeHeader(buf *bytes.Buffer, name string, typ string, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// labelEscaper escapes label values in the Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabel quotes a label value in the Prometheus text format.
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
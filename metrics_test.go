@@ -0,0 +1,61 @@
+package lifecycle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	s1 := newTestWorker("s1", 0, time.Second, nil)
+	s2 := newTestWorker("s2", 10*time.Second, 50*time.Millisecond, nil)
+	s3 := newTestWorker("s3", 10*time.Second, 10*time.Second, nil)
+	handler := MetricsHandler(s1.Worker, s2.Worker, s3.Worker)
+
+	assert.NoError(t, s1.doStart())
+	assert.NoError(t, s2.doStart())
+	assert.NoError(t, s3.doStart())
+	assert.NoError(t, s1.Shutdown())
+	assert.NoError(t, s2.Shutdown())
+	// Terminations during a shutdown are not escalations
+	go s3.Shutdown()
+	assert.Eventually(t, func() bool {
+		return s3.State() == ShuttingDown
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, s3.Terminate())
+	s1.ObserverEvents()
+	s2.ObserverEvents()
+	s3.ObserverEvents()
+
+	var body string
+	assert.Eventually(t, func() bool {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+		body = rw.Body.String()
+		return strings.Contains(body,
+			`lifecycle_shutdown_duration_seconds_count{service="s2"} 1`)
+	}, time.Second, time.Millisecond)
+
+	for _, line := range []string{
+		"# TYPE lifecycle_state gauge",
+		`lifecycle_state{service="s1",state="Stopped"} 1`,
+		`lifecycle_state{service="s1",state="Started"} 0`,
+		`lifecycle_transitions_total{service="s1",from="Initial",to="Starting"} 1`,
+		`lifecycle_transitions_total{service="s2",from="ShuttingDown",to="Terminating"} 1`,
+		`lifecycle_errors_total{service="s1"} 0`,
+		`lifecycle_shutdown_escalations_total{service="s1"} 0`,
+		`lifecycle_shutdown_escalations_total{service="s2"} 1`,
+		`lifecycle_shutdown_escalations_total{service="s3"} 0`,
+		"# TYPE lifecycle_startup_duration_seconds histogram",
+		`lifecycle_startup_duration_seconds_bucket{service="s1",le="+Inf"} 1`,
+		`lifecycle_shutdown_duration_seconds_bucket{service="s1",le="0.005"} 1`,
+		`lifecycle_shutdown_duration_seconds_bucket{service="s2",le="0.05"} 0`,
+		`lifecycle_shutdown_duration_seconds_bucket{service="s2",le="0.1"} 1`,
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+}
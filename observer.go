@@ -0,0 +1,268 @@
+package lifecycle
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ObservePolicy defines how events are delivered to an observer which is not
+// ready to receive them.
+type ObservePolicy uint8
+
+const (
+	// ObserveBlock delivers every event, blocking the caller causing the event,
+	// as well as the delivery of the next events, until the observer receives
+	// it or is removed with Unobserve. This is the default policy.
+	ObserveBlock ObservePolicy = iota
+	// ObserveDropOldest buffers events, dropping the oldest buffered event
+	// when the buffer is full.
+	ObserveDropOldest
+	// ObserveDropNewest buffers events, dropping the incoming event when the
+	// buffer is full.
+	ObserveDropNewest
+	// ObserveUnbounded buffers events without limit.
+	ObserveUnbounded
+)
+
+// ObserveOptions contains options for an observer.
+type ObserveOptions struct {
+	// Policy defines how events are delivered when the observer is not ready
+	// to receive them (default: ObserveBlock).
+	Policy ObservePolicy
+	// BufferSize defines the maximum number of events buffered by the
+	// ObserveDropOldest and ObserveDropNewest policies (default: 16).
+	BufferSize int
+	// KeepOpen prevents the chan from being closed when the service reaches a
+	// final state. The observer then remains registered until it is removed
+	// with Unobserve, including when the service is reset. This allows to
+	// share a chan between several services.
+	KeepOpen bool
+}
+
+func (o ObserveOptions) copy() *ObserveOptions {
+	return &o
+}
+
+// subscription delivers events to an observer according to its policy. Except
+// for the ObserveBlock policy, events are queued and delivered by a dedicated
+// goroutine, so that posting an event never blocks.
+type subscription struct {
+	// Observer chan
+	ch chan<- Event
+	// Observer options
+	opts *ObserveOptions
+	// Counts the events dropped by the worker
+	dropped *uint64
+	// Protects queue and final
+	mut sync.Mutex
+	// Events not delivered yet
+	queue []Event
+	// Whether the observer chan must be closed once the queue is drained
+	final bool
+	// Wakes the delivery goroutine up
+	wake chan struct{}
+	// Closed when the observer is removed
+	stop chan struct{}
+	// Prevent against double close of the stop chan
+	stopOnce sync.Once
+}
+
+// newSubscription creates a subscription delivering events to ch, and starts
+// its delivery goroutine if needed.
+func newSubscription(ch chan<- Event, opts *ObserveOptions,
+	dropped *uint64) *subscription {
+	if opts == nil {
+		opts = &ObserveOptions{}
+	}
+	opts = opts.copy()
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 16
+	}
+	s := &subscription{
+		ch:      ch,
+		opts:    opts,
+		dropped: dropped,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	if opts.Policy != ObserveBlock {
+		go s.run()
+	}
+	return s
+}
+
+// post delivers or queues an event. If final is true, the observer chan is
+// closed once the event is delivered, unless KeepOpen is set.
+func (s *subscription) post(event Event, final bool) {
+	final = final && !s.opts.KeepOpen
+	if s.opts.Policy == ObserveBlock {
+		select {
+		case s.ch <- event:
+		case <-s.stop:
+			return
+		}
+		if final {
+			close(s.ch)
+		}
+		return
+	}
+
+	s.mut.Lock()
+	switch {
+	case s.opts.Policy == ObserveUnbounded ||
+		len(s.queue) < s.opts.BufferSize:
+		s.queue = append(s.queue, event)
+	case s.opts.Policy == ObserveDropOldest:
+		s.queue = append(s.queue[1:], event)
+		atomic.AddUint64(s.dropped, 1)
+	default:
+		atomic.AddUint64(s.dropped, 1)
+	}
+	s.final = s.final || final
+	s.mut.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the queued events until the observer chan is closed or the
+// subscription is cancelled.
+func (s *subscription) run() {
+	for {
+		s.mut.Lock()
+		if len(s.queue) == 0 {
+			final := s.final
+			s.mut.Unlock()
+			if final {
+				close(s.ch)
+				return
+			}
+			select {
+			case <-s.wake:
+				continue
+			case <-s.stop:
+				return
+			}
+		}
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mut.Unlock()
+
+		select {
+		case s.ch <- event:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// cancel stops the delivery of events. The observer chan is not closed.
+func (s *subscription) cancel() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// callback is a function registered with OnTransition.
+type callback struct {
+	// Function called on state changes
+	fn func(Event)
+	// Target states for which fn is called, all if empty
+	states []State
+}
+
+// OnTransition registers a function called with the lifecycle events posted by
+// the service, such as state changes and errors. If states are provided, fn is
+// only called for events whose target state is one of them. Callbacks of a
+// service are called sequentially, in the order of the events, and remain
+// registered when the service reaches a final state. The returned function
+// unregisters fn.
+func (c *Worker) OnTransition(fn func(Event),
+	states ...State) (unsubscribe func()) {
+	if fn == nil {
+		return func() {}
+	}
+	cb := &callback{fn: fn, states: states}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.callbacks = append(c.callbacks, cb)
+	if c.callbackCh == nil {
+		c.callbackCh = make(chan Event)
+		c.callbackStop = make(chan struct{})
+		c.observersMut.Lock()
+		c.observers = append(c.observers, newSubscription(c.callbackCh,
+			&ObserveOptions{Policy: ObserveUnbounded, KeepOpen: true},
+			&c.dropped))
+		c.observersMut.Unlock()
+		go c.runCallbacks(c.callbackCh, c.callbackStop)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.removeCallback(cb)
+		})
+	}
+}
+
+// runCallbacks calls the registered callbacks with the events received on ch
+// until stop is closed.
+func (c *Worker) runCallbacks(ch <-chan Event, stop <-chan struct{}) {
+	for {
+		var event Event
+		select {
+		case event = <-ch:
+		case <-stop:
+			return
+		}
+		c.mut.Lock()
+		callbacks := append([]*callback(nil), c.callbacks...)
+		c.mut.Unlock()
+		for _, cb := range callbacks {
+			if len(cb.states) == 0 || isOneOf(event.To, cb.states) {
+				cb.fn(event)
+			}
+		}
+	}
+}
+
+// removeCallback unregisters a callback, and stops delivering events to
+// callbacks once none is registered anymore.
+func (c *Worker) removeCallback(cb *callback) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for i, o := range c.callbacks {
+		if o == cb {
+			c.callbacks = append(c.callbacks[:i], c.callbacks[i+1:]...)
+			break
+		}
+	}
+	if len(c.callbacks) > 0 || c.callbackCh == nil {
+		return
+	}
+	c.observersMut.Lock()
+	for i, o := range c.observers {
+		if o.ch == c.callbackCh {
+			o.cancel()
+			c.observers = append(c.observers[:i], c.observers[i+1:]...)
+			break
+		}
+	}
+	c.observersMut.Unlock()
+	close(c.callbackStop)
+	c.callbackCh = nil
+	c.callbackStop = nil
+}
+
+// isOneOf checks whether state is in the list of provided states.
+func isOneOf(state State, states []State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
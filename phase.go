@@ -0,0 +1,100 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+)
+
+// Phase identifies the optional hooks run around the main hooks of a service,
+// such as PreStart or PostStop.
+type Phase uint8
+
+const (
+	// NoPhase identifies events which are not related to a phase hook.
+	NoPhase Phase = iota
+	// PreStart runs right before the Start hook, for example to run
+	// migrations or warm a cache up.
+	PreStart
+	// PostStart runs once the service is ready and Started, for example to
+	// register the service with a service discovery.
+	PostStart
+	// PreStop runs when the service is shut down gracefully, before the
+	// PreStopDelay and the Shutdown hook, for example to deregister the
+	// service. The run context of the service is not cancelled yet.
+	PreStop
+	// PostStop runs once the service stopped, before it transitions to
+	// Stopped, for example to flush telemetry.
+	PostStop
+)
+
+func (p Phase) String() string {
+	switch p {
+	case NoPhase:
+		return "NoPhase"
+	case PreStart:
+		return "PreStart"
+	case PostStart:
+		return "PostStart"
+	case PreStop:
+		return "PreStop"
+	case PostStop:
+		return "PostStop"
+	default:
+		return fmt.Sprintf("%d", int(p))
+	}
+}
+
+// phaseKey is the context key of the phase of an event.
+type phaseKey struct{}
+
+// withPhase returns a context carrying the phase of the events it causes.
+func withPhase(ctx context.Context, phase Phase) context.Context {
+	return context.WithValue(ctx, phaseKey{}, phase)
+}
+
+// phaseFromContext returns the phase carried by ctx, if any.
+func phaseFromContext(ctx context.Context) Phase {
+	if ctx == nil {
+		return NoPhase
+	}
+	phase, _ := ctx.Value(phaseKey{}).(Phase)
+	return phase
+}
+
+// runPhase runs the hook of the provided phase, if defined, within the timeout
+// of the phase. Observers are notified with an event carrying the phase before
+// the hook is called. Errors are wrapped to identify the phase, and handled
+// with handleError.
+func (c *Worker) runPhase(ctx context.Context, phase Phase,
+	hook ContextHook) error {
+	if hook == nil {
+		return nil
+	}
+	ctx = withPhase(ctx, phase)
+	c.info("running phase hook", "phase", phase.String())
+	c.notify(ctx, nil)
+
+	hookCtx := ctx
+	timeout := c.opts.PhaseTimeouts[phase]
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		hookCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := callWithTimeout(hookCtx, hook, timeout)
+	if err != nil {
+		return c.handleError(ctx, fmt.Errorf("%s hook failed: %w", phase, err))
+	}
+	return nil
+}
+
+// runPostStop runs the PostStop hook once per run of the service. Concurrent
+// callers wait for the hook to return. The hook is not cancelled along with
+// ctx, which may be expired after a shutdown timeout.
+func (c *Worker) runPostStop(ctx context.Context) error {
+	var err error
+	c.postStopOnce.Do(func() {
+		err = c.runPhase(detach(ctx), PostStop, c.hooks.PostStop)
+	})
+	return err
+}
@@ -0,0 +1,320 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ProbeOptions contains options for the readiness probes created by the probe
+// helpers, such as TCPProbe or PollProbe. The condition checked by the probe is
+// polled until it is met, or until the probe gives up.
+type ProbeOptions struct {
+	// Interval between two attempts (default: 100 milliseconds).
+	Interval time.Duration
+	// Backoff, if defined, replaces Interval with a delay growing with the
+	// number of attempts.
+	Backoff *Backoff
+	// AttemptTimeout defines a maximum amount of time for a single attempt
+	// (default: 1 second).
+	AttemptTimeout time.Duration
+	// Timeout defines a maximum amount of time for the condition to be met.
+	// When the specified amount of time is elapsed, the probe gives up and
+	// reports the last error returned by the condition. A negative value
+	// disables the timeout (default: 1 minute).
+	Timeout time.Duration
+}
+
+func (o ProbeOptions) copy() *ProbeOptions {
+	return &o
+}
+
+// PollProbe creates a readiness probe polling check until it returns nil. The
+// context provided to check is cancelled when the attempt times out.
+func PollProbe(check func(ctx context.Context) error,
+	opts *ProbeOptions) func() <-chan error {
+	probe := PollProbeCtx(check, opts)
+	return func() <-chan error {
+		return probe(context.Background())
+	}
+}
+
+// PollProbeCtx creates a context-aware readiness probe polling check until it
+// returns nil, suitable for ServiceOptions.ReadinessProbeCtx. The probe gives
+// up when the context provided to the probe is cancelled.
+func PollProbeCtx(check func(ctx context.Context) error,
+	opts *ProbeOptions) func(ctx context.Context) <-chan error {
+	if opts == nil {
+		opts = &ProbeOptions{}
+	}
+	opts = opts.copy()
+	if opts.Interval <= 0 {
+		opts.Interval = 100 * time.Millisecond
+	}
+	if opts.AttemptTimeout <= 0 {
+		opts.AttemptTimeout = time.Second
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Minute
+	}
+	return func(ctx context.Context) <-chan error {
+		ch := make(chan error, 1)
+		go func() {
+			defer close(ch)
+			if err := poll(ctx, check, opts); err != nil {
+				ch <- err
+			}
+		}()
+		return ch
+	}
+}
+
+// TCPProbe creates a readiness probe waiting for a TCP connection to addr to
+// succeed.
+func TCPProbe(addr string, opts *ProbeOptions) func() <-chan error {
+	return PollProbe(func(ctx context.Context) error {
+		return dial(ctx, "tcp", addr)
+	}, opts)
+}
+
+// UnixSocketProbe creates a readiness probe waiting for the unix socket at
+// path to accept connections.
+func UnixSocketProbe(path string, opts *ProbeOptions) func() <-chan error {
+	return PollProbe(func(ctx context.Context) error {
+		return dial(ctx, "unix", path)
+	}, opts)
+}
+
+// HTTPProbe creates a readiness probe waiting for a GET request to url to
+// return the provided status code, or 200 OK if status is 0.
+func HTTPProbe(url string, status int, opts *ProbeOptions) func() <-chan error {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return PollProbe(func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		io.Copy(ioutil.Discard, res.Body)
+		if res.StatusCode != status {
+			return fmt.Errorf("unexpected status %d from %s, expected %d",
+				res.StatusCode, url, status)
+		}
+		return nil
+	}, opts)
+}
+
+// FileProbe creates a readiness probe waiting for the file at path to exist.
+func FileProbe(path string, opts *ProbeOptions) func() <-chan error {
+	return PollProbe(func(ctx context.Context) error {
+		_, err := os.Stat(path)
+		return err
+	}, opts)
+}
+
+// poll calls check until it returns nil, or until the probe times out or ctx
+// is cancelled. In the latter cases, the last error returned by check is
+// reported.
+func poll(ctx context.Context, check func(ctx context.Context) error,
+	opts *ProbeOptions) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	var last error
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, opts.AttemptTimeout)
+		err := check(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		// Keep the error of the previous attempt if this one was interrupted
+		if ctx.Err() == nil || last == nil {
+			last = err
+		}
+
+		delay := opts.Interval
+		if opts.Backoff != nil {
+			delay = opts.Backoff.Delay(attempt)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("readiness probe gave up after %d attempts: %w",
+				attempt+1, last)
+		}
+	}
+}
+
+// dial checks that a connection to the provided address succeeds.
+func dial(ctx context.Context, network, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// RetryPolicy defines how a failed readiness probe is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// A negative value allows an unlimited number of attempts (default: 3).
+	MaxAttempts int
+	// Backoff defines the delay between attempts.
+	Backoff Backoff
+}
+
+// All creates a readiness probe which succeeds once all the provided probes
+// succeeded. The probes are executed concurrently. If any of them fails, the
+// errors of all the failed probes are reported once all of them completed.
+func All(probes ...func() <-chan error) func() <-chan error {
+	return func() <-chan error {
+		ch := make(chan error, 1)
+		go func() {
+			defer close(ch)
+			var errs *multierror.Error
+			for err := range runProbes(probes) {
+				if err != nil {
+					errs = multierror.Append(errs, err)
+				}
+			}
+			if err := errs.ErrorOrNil(); err != nil {
+				ch <- err
+			}
+		}()
+		return ch
+	}
+}
+
+// Any creates a readiness probe which succeeds as soon as one of the provided
+// probes succeeds. The probes are executed concurrently. If all of them fail,
+// their errors are reported.
+func Any(probes ...func() <-chan error) func() <-chan error {
+	return func() <-chan error {
+		ch := make(chan error, 1)
+		go func() {
+			defer close(ch)
+			if len(probes) == 0 {
+				return
+			}
+			var errs *multierror.Error
+			for err := range runProbes(probes) {
+				if err == nil {
+					return
+				}
+				errs = multierror.Append(errs, err)
+			}
+			ch <- errs.ErrorOrNil()
+		}()
+		return ch
+	}
+}
+
+// WithTimeout creates a readiness probe which fails with a timeout error if
+// the provided probe does not complete within the specified amount of time.
+// See IsTimeout.
+func WithTimeout(probe func() <-chan error,
+	timeout time.Duration) func() <-chan error {
+	return func() <-chan error {
+		ch := make(chan error, 1)
+		go func() {
+			defer close(ch)
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			select {
+			case err := <-runProbe(probe):
+				if err != nil {
+					ch <- err
+				}
+			case <-timer.C:
+				ch <- fmt.Errorf("readiness probe did not complete in %s: %w",
+					timeout, errTimeout)
+			}
+		}()
+		return ch
+	}
+}
+
+// Retry creates a readiness probe executing the provided probe again when it
+// fails, according to the retry policy. The errors of all the attempts are
+// reported when the maximum number of attempts is reached.
+func Retry(probe func() <-chan error, policy *RetryPolicy) func() <-chan error {
+	if policy == nil {
+		policy = &RetryPolicy{}
+	}
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = 3
+	}
+	backoff := policy.Backoff
+	return func() <-chan error {
+		ch := make(chan error, 1)
+		go func() {
+			defer close(ch)
+			var errs *multierror.Error
+			for attempt := 0; maxAttempts < 0 || attempt < maxAttempts; attempt++ {
+				if attempt > 0 {
+					time.Sleep(backoff.Delay(attempt - 1))
+				}
+				err := <-runProbe(probe)
+				if err == nil {
+					return
+				}
+				errs = multierror.Append(errs,
+					fmt.Errorf("attempt %d: %w", attempt+1, err))
+			}
+			ch <- errs.ErrorOrNil()
+		}()
+		return ch
+	}
+}
+
+// runProbe executes a readiness probe. A nil probe succeeds immediately.
+func runProbe(probe func() <-chan error) <-chan error {
+	if probe == nil {
+		ch := make(chan error)
+		close(ch)
+		return ch
+	}
+	return probe()
+}
+
+// runProbes executes the provided readiness probes concurrently, and returns a
+// chan on which their results are posted as they complete. The chan is closed
+// once all of them completed.
+func runProbes(probes []func() <-chan error) <-chan error {
+	ch := make(chan error, len(probes))
+	var wg sync.WaitGroup
+	for _, probe := range probes {
+		wg.Add(1)
+		go func(probe func() <-chan error) {
+			defer wg.Done()
+			ch <- <-runProbe(probe)
+		}(probe)
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+	return ch
+}
@@ -0,0 +1,168 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollProbe(t *testing.T) {
+	attempts := 0
+	probe := PollProbe(func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("not ready")
+		}
+		return nil
+	}, &ProbeOptions{Interval: time.Millisecond})
+	assert.NoError(t, <-probe())
+	assert.Equal(t, 3, attempts)
+}
+
+func TestPollProbeTimeout(t *testing.T) {
+	probe := PollProbe(func(ctx context.Context) error {
+		return errors.New("not ready")
+	}, &ProbeOptions{
+		Backoff: &Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond},
+		Timeout: 50 * time.Millisecond,
+	})
+	err := <-probe()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not ready")
+}
+
+func TestTCPProbe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := l.Addr().String()
+	assert.NoError(t, <-TCPProbe(addr, nil)())
+	l.Close()
+	err = <-TCPProbe(addr, &ProbeOptions{
+		Interval: time.Millisecond,
+		Timeout:  20 * time.Millisecond,
+	})()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "dial tcp")
+}
+
+func TestUnixSocketProbe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lifecycle")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "socket")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if l, err := net.Listen("unix", path); err == nil {
+			defer l.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+	assert.NoError(t, <-UnixSocketProbe(path, &ProbeOptions{
+		Interval: time.Millisecond,
+	})())
+}
+
+func TestHTTPProbe(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(status)
+		}))
+	defer srv.Close()
+	err := <-HTTPProbe(srv.URL, 0, &ProbeOptions{
+		Interval: time.Millisecond,
+		Timeout:  20 * time.Millisecond,
+	})()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected status 503")
+	assert.NoError(t, <-HTTPProbe(srv.URL, status, nil)())
+}
+
+func TestFileProbe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lifecycle")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ready")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		ioutil.WriteFile(path, nil, 0644)
+	}()
+	assert.NoError(t, <-FileProbe(path, &ProbeOptions{
+		Interval: time.Millisecond,
+	})())
+}
+
+func TestProbeCombinators(t *testing.T) {
+	ok := Wait(time.Millisecond)
+	never := func() <-chan error {
+		return make(chan error)
+	}
+	fail := func(msg string) func() <-chan error {
+		return func() <-chan error {
+			ch := make(chan error, 1)
+			ch <- errors.New(msg)
+			return ch
+		}
+	}
+
+	assert.NoError(t, <-All()())
+	assert.NoError(t, <-All(ok, ok, nil)())
+	err := <-All(ok, fail("oops1"), fail("oops2"))()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "oops1")
+	assert.Contains(t, err.Error(), "oops2")
+
+	assert.NoError(t, <-Any()())
+	assert.NoError(t, <-Any(fail("oops"), ok, never)())
+	err = <-Any(fail("oops1"), fail("oops2"))()
+	assert.Contains(t, err.Error(), "oops1")
+	assert.Contains(t, err.Error(), "oops2")
+
+	assert.NoError(t, <-WithTimeout(ok, time.Second)())
+	assert.True(t, IsTimeout(<-WithTimeout(never, time.Millisecond)()))
+	assert.True(t, IsTimeout(<-All(ok, WithTimeout(never, time.Millisecond))()))
+}
+
+func TestRetryProbe(t *testing.T) {
+	attempts := 0
+	probe := func() <-chan error {
+		attempts++
+		ch := make(chan error, 1)
+		if attempts < 3 {
+			ch <- fmt.Errorf("oops%d", attempts)
+		}
+		close(ch)
+		return ch
+	}
+	policy := &RetryPolicy{Backoff: Backoff{Initial: time.Millisecond}}
+	assert.NoError(t, <-Retry(probe, policy)())
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	policy.MaxAttempts = 2
+	err := <-Retry(probe, policy)()
+	assert.Equal(t, 2, attempts)
+	assert.Contains(t, err.Error(), "attempt 1: oops1")
+	assert.Contains(t, err.Error(), "attempt 2: oops2")
+}
+
+func TestPollProbeCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := PollProbeCtx(func(ctx context.Context) error {
+		return errors.New("not ready")
+	}, &ProbeOptions{Interval: time.Millisecond})(ctx)
+	cancel()
+	err := <-ch
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not ready")
+}
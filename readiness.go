@@ -0,0 +1,100 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// readyKey is the context key of the ready notifier of a Start hook.
+type readyKey struct{}
+
+// NotifyReady signals that the service is ready, for example once a server has
+// bound its listeners. It must be called from the Start hook of a service with
+// ReadyNotify set, with the context provided to the hook. Calling it several
+// times has no further effect. It returns false if ctx does not carry a ready
+// notifier.
+func NotifyReady(ctx context.Context) bool {
+	notify, ok := ctx.Value(readyKey{}).(func())
+	if ok {
+		notify()
+	}
+	return ok
+}
+
+// withReadyNotifier returns a context carrying a ready notifier, and a chan
+// closed once NotifyReady is called with this context.
+func withReadyNotifier(ctx context.Context) (context.Context,
+	<-chan struct{}) {
+	ch := make(chan struct{})
+	var once sync.Once
+	return context.WithValue(ctx, readyKey{}, func() {
+		once.Do(func() {
+			close(ch)
+		})
+	}), ch
+}
+
+// readinessProbe returns the context-aware readiness probe defined by the
+// options, if any.
+func (o *ServiceOptions) readinessProbe() func(
+	ctx context.Context) <-chan error {
+	if o.ReadinessProbeCtx != nil {
+		return o.ReadinessProbeCtx
+	}
+	if probe := o.ReadinessProbe; probe != nil {
+		return func(ctx context.Context) <-chan error {
+			return probe()
+		}
+	}
+	return nil
+}
+
+// waitReady waits for the ready notification if notified is not nil, then for
+// the readiness probe to complete, within StartTimeout. It returns nil without
+// waiting further once the done chan is closed. The context provided to the
+// probe derives from ctx, and is cancelled when this function returns.
+func (c *Worker) waitReady(ctx context.Context, notified <-chan struct{},
+	done <-chan struct{}) error {
+	var timeout <-chan time.Time
+	if c.opts.StartTimeout > 0 {
+		timer := time.NewTimer(c.opts.StartTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	timeoutErr := fmt.Errorf("service did not become ready in %s: %w",
+		c.opts.StartTimeout, errTimeout)
+
+	if notified != nil {
+		c.info("waiting for ready notification")
+		select {
+		case <-notified:
+		case <-timeout:
+			return timeoutErr
+		case <-done:
+			c.info("interrupting ready notification wait")
+			return nil
+		}
+	}
+
+	if c.opts.ReadinessProbeCtx != nil {
+		c.info("waiting for readiness")
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		select {
+		case err := <-c.opts.ReadinessProbeCtx(ctx):
+			// The probe is cancelled if the service is shut down meanwhile
+			if err != nil && ctx.Err() != nil {
+				return fmt.Errorf("readiness probe cancelled: %v: %w", err,
+					errInterrupted)
+			}
+			return err
+		case <-timeout:
+			return timeoutErr
+		case <-done:
+			c.info("interrupting readiness probe")
+		}
+	}
+	return nil
+}
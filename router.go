@@ -0,0 +1,154 @@
+package lifecycle
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// DefaultSignalRouter is the SignalRouter used by services which do not
+// define their own router.
+var DefaultSignalRouter = NewSignalRouter()
+
+// SignalRouter is a process-level router of signals. It installs a single
+// handler for all the signals its routes are registered for, and dispatches
+// each received signal once to the matching routes, in the order in which
+// they were registered. Routes are called sequentially, so that services
+// sharing a router handle signals in a deterministic order.
+//
+// Signals can also be injected with Dispatch, for example in tests.
+type SignalRouter struct {
+	// Receives the signals from the os/signal package
+	ch chan os.Signal
+	// Protects routes and notified
+	mut sync.Mutex
+	// Registered routes, in registration order
+	routes []*route
+	// Signals relayed to ch
+	notified map[os.Signal]bool
+	// Serializes dispatches, so that signals are handled in order
+	dispatchMut sync.Mutex
+	// Starts the goroutine receiving the signals
+	runOnce sync.Once
+}
+
+// route is a handler registered with a SignalRouter.
+type route struct {
+	// Function called when one of the signals is received
+	fn func(os.Signal)
+	// Signals routed to fn
+	signals []os.Signal
+}
+
+// NewSignalRouter creates a SignalRouter. Signals are only relayed by the
+// router once a route is registered for them.
+func NewSignalRouter() *SignalRouter {
+	return &SignalRouter{
+		ch:       make(chan os.Signal, 16),
+		notified: make(map[os.Signal]bool),
+	}
+}
+
+// Register registers a route calling fn when one of the provided signals is
+// received. The returned function unregisters the route ; once no route of the
+// router is registered for a signal anymore, the router stops relaying it.
+func (r *SignalRouter) Register(fn func(os.Signal),
+	signals ...os.Signal) (unregister func()) {
+	if fn == nil || len(signals) == 0 {
+		return func() {}
+	}
+	rt := &route{fn: fn, signals: signals}
+
+	r.runOnce.Do(func() {
+		go r.run()
+	})
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.routes = append(r.routes, rt)
+	r.update()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.remove(rt)
+		})
+	}
+}
+
+// Dispatch synchronously calls the routes registered for the provided signal,
+// in registration order, as if the signal was received by the process.
+func (r *SignalRouter) Dispatch(sig os.Signal) {
+	r.dispatchMut.Lock()
+	defer r.dispatchMut.Unlock()
+
+	r.mut.Lock()
+	routes := append([]*route(nil), r.routes...)
+	r.mut.Unlock()
+	for _, rt := range routes {
+		for _, s := range rt.signals {
+			if s == sig {
+				rt.fn(sig)
+				break
+			}
+		}
+	}
+}
+
+// run dispatches the signals received by the process.
+func (r *SignalRouter) run() {
+	for sig := range r.ch {
+		r.Dispatch(sig)
+	}
+}
+
+// remove unregisters a route.
+func (r *SignalRouter) remove(rt *route) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	for i, o := range r.routes {
+		if o == rt {
+			r.routes = append(r.routes[:i], r.routes[i+1:]...)
+			break
+		}
+	}
+	r.update()
+}
+
+// update relays the signals for which a route is registered. Signals which
+// were already relayed are left untouched, so that their default behavior is
+// never restored while a route is registered for them. It must be called with
+// the lock held.
+func (r *SignalRouter) update() {
+	var signals, added []os.Signal
+	removed := false
+	notified := make(map[os.Signal]bool, len(r.notified))
+	for _, rt := range r.routes {
+		for _, sig := range rt.signals {
+			if notified[sig] {
+				continue
+			}
+			notified[sig] = true
+			signals = append(signals, sig)
+			if !r.notified[sig] {
+				added = append(added, sig)
+			}
+		}
+	}
+	for sig := range r.notified {
+		if !notified[sig] {
+			removed = true
+		}
+	}
+	r.notified = notified
+
+	// Signals can only be dropped by stopping the relay to ch altogether, so
+	// the signals which are still routed are relayed again right after.
+	if removed {
+		signal.Stop(r.ch)
+		if len(signals) > 0 {
+			signal.Notify(r.ch, signals...)
+		}
+	} else if len(added) > 0 {
+		signal.Notify(r.ch, added...)
+	}
+}
@@ -0,0 +1,105 @@
+package lifecycle
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignalRouter(t *testing.T) {
+	var log []string
+	r := NewSignalRouter()
+	unregister1 := r.Register(func(sig os.Signal) {
+		log = append(log, "1 "+sig.String())
+	}, syscall.SIGUSR1, syscall.SIGUSR2)
+	unregister2 := r.Register(func(sig os.Signal) {
+		log = append(log, "2 "+sig.String())
+	}, syscall.SIGUSR2)
+	r.Dispatch(syscall.SIGUSR2)
+	r.Dispatch(syscall.SIGUSR1)
+	unregister1()
+	unregister1()
+	r.Dispatch(syscall.SIGUSR2)
+	unregister2()
+	r.Dispatch(syscall.SIGUSR2)
+	assert.Equal(t, []string{
+		"1 user defined signal 2",
+		"2 user defined signal 2",
+		"1 user defined signal 1",
+		"2 user defined signal 2",
+	}, log)
+}
+
+func TestSignalRouterWorkers(t *testing.T) {
+	r := NewSignalRouter()
+	s1 := newTestWorker("s1", 0, time.Second, nil)
+	s2 := newTestWorker("s2", 0, time.Second, nil)
+	s1.opts.SignalRouter = r
+	s2.opts.SignalRouter = r
+	assert.NoError(t, s1.doStart())
+	assert.NoError(t, s2.doStart())
+	r.mut.Lock()
+	assert.Len(t, r.routes, 2)
+	r.mut.Unlock()
+	r.Dispatch(syscall.SIGUSR2)
+	assert.Equal(t, []State{Starting, Started, ShuttingDown, Stopped},
+		s1.ObserverEventSequence())
+	assert.Equal(t, []State{Starting, Started, ShuttingDown, Stopped},
+		s2.ObserverEventSequence())
+	assert.Eventually(t, func() bool {
+		r.mut.Lock()
+		defer r.mut.Unlock()
+		return len(r.routes) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestSignalRouterRelay(t *testing.T) {
+	r1 := NewSignalRouter()
+	r2 := NewSignalRouter()
+	received := make(chan os.Signal, 1)
+	unregister1 := r1.Register(func(sig os.Signal) {
+		received <- sig
+	}, syscall.SIGUSR1)
+	unregister2 := r1.Register(func(sig os.Signal) {}, syscall.SIGUSR1,
+		syscall.SIGUSR2)
+	unregister3 := r2.Register(func(sig os.Signal) {}, syscall.SIGUSR1)
+
+	// The signal remains relayed while a route is registered for it
+	unregister2()
+	unregister3()
+	r1.mut.Lock()
+	assert.Equal(t, map[os.Signal]bool{syscall.SIGUSR1: true}, r1.notified)
+	r1.mut.Unlock()
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	select {
+	case sig := <-received:
+		assert.Equal(t, syscall.SIGUSR1, sig)
+	case <-time.After(time.Second):
+		t.Fatal("signal not relayed")
+	}
+
+	// Signals relayed by the application itself are not affected by the router
+	mine := make(chan os.Signal, 1)
+	signal.Notify(mine, syscall.SIGUSR1)
+	defer signal.Stop(mine)
+	unregister1()
+	r1.mut.Lock()
+	assert.Empty(t, r1.notified)
+	r1.mut.Unlock()
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+	select {
+	case sig := <-mine:
+		assert.Equal(t, syscall.SIGUSR1, sig)
+	case <-time.After(time.Second):
+		t.Fatal("signal not relayed to the application")
+	}
+	select {
+	case <-received:
+		t.Fatal("signal relayed to an unregistered route")
+	default:
+	}
+}
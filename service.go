@@ -25,6 +25,20 @@ type Service interface {
 	Shutdown() error
 	// ShutdownCtx shuts the service down gracefully providing context.
 	ShutdownCtx(ctx context.Context) error
+	// Pause temporarily pauses the service, without shutting it down.
+	Pause() error
+	// PauseCtx temporarily pauses the service providing context.
+	PauseCtx(ctx context.Context) error
+	// Resume resumes a paused service.
+	Resume() error
+	// ResumeCtx resumes a paused service providing context.
+	ResumeCtx(ctx context.Context) error
+	// Reset re-arms a stopped service or a service in an Error state, so that
+	// it can be started again.
+	Reset() error
+	// ResetCtx re-arms a stopped service or a service in an Error state
+	// providing context.
+	ResetCtx(ctx context.Context) error
 	// Terminate forcefully terminates the service.
 	Terminate() error
 	// TerminateCtx forcefully terminates the service providing context.
@@ -37,6 +51,8 @@ type Service interface {
 	Done() <-chan struct{}
 	// State returns the current state of the service.
 	State() State
+	// Err returns the last error reported by the service, if any.
+	Err() error
 	// Observes registers a chan on which the service will post lifecycle events
 	// such as state changes and errors. No action is taken if ch is nil.
 	Observe(ch chan<- Event)
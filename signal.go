@@ -0,0 +1,161 @@
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"time"
+)
+
+// exit exits the process ; replaced in tests.
+var exit = os.Exit
+
+// forceExitNotifyTimeout is the maximum amount of time spent notifying the
+// observers of a forced exit before exiting.
+const forceExitNotifyTimeout = 100 * time.Millisecond
+
+// SignalHandler is a custom handler called when a signal is received.
+type SignalHandler = func(w *Worker, sig os.Signal)
+
+// DumpState is a SignalHandler logging the current state and last error of the
+// worker.
+func DumpState(w *Worker, sig os.Signal) {
+	w.info("dumping state", "signal", sig, "state", w.State().String(),
+		"error", w.Err())
+}
+
+// DumpGoroutines is a SignalHandler logging the stack traces of all the
+// goroutines of the process.
+func DumpGoroutines(w *Worker, sig os.Signal) {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	w.info("dumping goroutines", "signal", sig, "goroutines", string(buf))
+}
+
+type managedKey struct{}
+
+// withManaged returns a context marking the services started with it as
+// managed by another service, which handles signals on their behalf.
+func withManaged(ctx context.Context) context.Context {
+	return context.WithValue(ctx, managedKey{}, true)
+}
+
+// isManaged returns true if ctx was returned by withManaged.
+func isManaged(ctx context.Context) bool {
+	managed, _ := ctx.Value(managedKey{}).(bool)
+	return managed
+}
+
+// signalActions builds the table of actions bound to signals from the
+// provided options.
+func signalActions(opts *ServiceOptions) map[os.Signal]Action {
+	actions := make(map[os.Signal]Action)
+	for _, sig := range opts.Signals {
+		actions[sig] = opts.SignalAction
+	}
+	for _, sig := range opts.ReloadSignals {
+		actions[sig] = Reload
+	}
+	for sig, action := range opts.SignalActions {
+		actions[sig] = action
+	}
+	return actions
+}
+
+// signals returns the signals bound to either an action or a handler.
+func (c *Worker) signals() []os.Signal {
+	var signals []os.Signal
+	for sig := range c.opts.SignalActions {
+		signals = append(signals, sig)
+	}
+	for sig := range c.opts.SignalHandlers {
+		if _, ok := c.opts.SignalActions[sig]; !ok {
+			signals = append(signals, sig)
+		}
+	}
+	return signals
+}
+
+// handleSignals registers the worker with its signal router for the provided
+// signals until the done chan is closed, and handles them according to the
+// signal tables.
+func (c *Worker) handleSignals(ctx context.Context, signals []os.Signal,
+	done <-chan struct{}) {
+	// Number of signals bound to Shutdown or Terminate received so far ;
+	// routes are called sequentially by the router.
+	stops := 0
+	unregister := c.opts.SignalRouter.Register(func(sig os.Signal) {
+		c.info("received signal", "signal", sig)
+		c.handleSignal(ctx, sig, &stops)
+	}, signals...)
+	go func() {
+		<-done
+		unregister()
+	}()
+}
+
+// handleSignal calls the handler bound to the provided signal, if any, and
+// takes the bound action. Repeated signals bound to Shutdown or Terminate
+// escalate the action: a graceful shutdown is escalated to a termination, and
+// a termination to a forced exit if enabled. Invalid state errors are ignored,
+// as they are expected when a signal is received multiple times.
+func (c *Worker) handleSignal(ctx context.Context, sig os.Signal,
+	stops *int) {
+	if handler := c.opts.SignalHandlers[sig]; handler != nil {
+		handler(c, sig)
+	}
+
+	reason := "signal " + sig.String()
+	action := c.opts.SignalActions[sig]
+	if action == Shutdown || action == Terminate {
+		*stops++
+		level := *stops - 1
+		if action == Terminate {
+			level++
+		}
+		switch {
+		case level >= 2 && c.opts.ForceExitCode != 0:
+			c.info("received signal during termination -- exiting",
+				"signal", sig, "code", c.opts.ForceExitCode)
+			// Observers are notified before exiting, but a blocked observer
+			// cannot delay the exit beyond forceExitNotifyTimeout.
+			callWithTimeout(withReason(ctx, "forced exit on signal "+sig.String()),
+				func(ctx context.Context) error {
+					c.notify(ctx, nil)
+					return nil
+				}, forceExitNotifyTimeout)
+			exit(c.opts.ForceExitCode)
+			return
+		case level >= 1 && action == Shutdown:
+			c.info("received signal during shutdown -- terminating",
+				"signal", sig)
+			action = Terminate
+			reason = "escalation on signal " + sig.String()
+		}
+	}
+
+	var fn func(ctx context.Context) error
+	switch action {
+	case Shutdown:
+		fn = c.ShutdownCtx
+	case Terminate:
+		fn = c.TerminateCtx
+	case Reload:
+		fn = c.ReloadCtx
+	default:
+		return
+	}
+	go func() {
+		err := fn(withReason(context.Background(), reason))
+		if !IsInvalidState(err) {
+			c.handleError(ctx, err)
+		}
+	}()
+}
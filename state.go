@@ -13,8 +13,18 @@ import "fmt"
 //     +----+ Starting     +----+
 //     |    +-+------------+    |
 //     |      |                 |
+//     |    +-v------------+    |    +--------------+
+//     +----+ Started      +----+----> Reloading    |
+//     |    +-+--^-------^-+    |    +-+------------+
+//     |      |  |       |      |      |
+//     |      |  |       +------+------+
+//     |      |  |              |    +--------------+
+//     |      |  +--------------+----+ Paused       |
+//     |      |                 |    +-^------------+
+//     |      +-----------------+------+
+//     |      |                 |
 //     |    +-v------------+    |
-//     +----+ Started      +----+
+//     +----+ Draining     +----+
 //     |    +-+------------+    |
 //     |      |                 |
 //     |    +-v------------+    |
@@ -28,6 +38,10 @@ import "fmt"
 //     |    +--------------+    |
 //     +----> Error        <----+
 //          +--------------+
+//
+// A service in a Reloading or Paused state can be shut down, terminated or
+// transition to an Error state the same way as a Started service. The Draining
+// state is skipped unless a PreStopDelay is defined.
 type State uint8
 
 const (
@@ -45,8 +59,23 @@ const (
 	Stopped
 	// Error represents a service having reached an error.
 	Error
+	// Reloading represents a service reloading its configuration. The service
+	// transitions back to Started once reloaded.
+	Reloading
+	// Paused represents a service that temporarily stopped processing work,
+	// without being shut down. The service transitions back to Started once
+	// resumed.
+	Paused
+	// Draining represents a service waiting for its PreStopDelay to elapse
+	// before being shut down gracefully. The service keeps running, but is
+	// not considered ready anymore.
+	Draining
 )
 
+// states lists all the states of the state machine.
+var states = []State{Initial, Starting, Started, ShuttingDown, Terminating,
+	Stopped, Error, Reloading, Paused, Draining}
+
 func (s State) String() string {
 	switch s {
 	case Initial:
@@ -63,6 +92,12 @@ func (s State) String() string {
 		return "Stopped"
 	case Error:
 		return "Error"
+	case Reloading:
+		return "Reloading"
+	case Paused:
+		return "Paused"
+	case Draining:
+		return "Draining"
 	default:
 		return fmt.Sprintf("%d", int(s))
 	}
@@ -80,4 +115,6 @@ const (
 	Shutdown
 	// Terminate the service.
 	Terminate
+	// Reload the service.
+	Reload
 )
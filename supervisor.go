@@ -0,0 +1,355 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RestartPolicy defines when a supervised service is restarted.
+type RestartPolicy uint8
+
+const (
+	// RestartOnFailure restarts the service when it transitions to an Error
+	// state or reports an error to its observers, for example when its
+	// liveness probe fails. This is the default policy.
+	RestartOnFailure RestartPolicy = iota
+	// RestartAlways restarts the service whenever it stops, unless the
+	// supervisor is being shut down or terminated.
+	RestartAlways
+	// RestartNever never restarts the service.
+	RestartNever
+)
+
+// SupervisorOptions contains options for the supervisor.
+type SupervisorOptions struct {
+	// Policy defines when the supervised service is restarted (default:
+	// RestartOnFailure).
+	Policy RestartPolicy
+	// Backoff defines the delay between restarts. The delay grows with the
+	// number of restarts within RestartWindow.
+	Backoff Backoff
+	// MaxRestarts is the maximum number of restarts allowed within
+	// RestartWindow. When this limit is exceeded, the supervisor transitions
+	// to an Error state. A negative value allows an unlimited number of
+	// restarts (default: 3).
+	MaxRestarts int
+	// RestartWindow is the period of time over which restarts are counted
+	// (default: 1 minute).
+	RestartWindow time.Duration
+}
+
+func (o SupervisorOptions) copy() *SupervisorOptions {
+	return &o
+}
+
+// Supervisor is a Service running a service created by a factory, and
+// restarting it according to a restart policy. The supervisor is ready once
+// the first instance of the supervised service is ready. Shutting down or
+// terminating the supervisor shuts down or terminates the current instance
+// without restarting it.
+//
+// As for a Host, the supervised service does not listen to signals itself,
+// which are handled by the supervisor.
+type Supervisor struct {
+	*Worker
+	// Creates instances of the supervised service
+	factory func() Service
+	// Supervisor options
+	supervisorOpts *SupervisorOptions
+	// Protects current and stopping
+	supervisorMut sync.Mutex
+	// Current instance of the supervised service
+	current Service
+	// Closed when the supervisor is shut down or terminated
+	stopping chan struct{}
+	// Closed when the first instance of the service is ready
+	started chan struct{}
+	// Prevent against double close of the started chan
+	startedOnce sync.Once
+}
+
+// NewSupervisor creates a Supervisor running the services created by factory.
+// It returns nil if factory is nil.
+func NewSupervisor(name string, factory func() Service,
+	opts *SupervisorOptions) *Supervisor {
+	return NewSupervisorWithOptions(name, factory, opts, nil)
+}
+
+// NewSupervisorWithOptions creates a Supervisor running the services created
+// by factory, with the provided service options. If a readiness probe is
+// provided, it is executed once the first instance of the service is ready. It
+// returns nil if factory is nil.
+func NewSupervisorWithOptions(name string, factory func() Service,
+	opts *SupervisorOptions, serviceOpts *ServiceOptions) *Supervisor {
+	if factory == nil {
+		return nil
+	}
+	if opts == nil {
+		opts = &SupervisorOptions{}
+	}
+	if serviceOpts == nil {
+		serviceOpts = &ServiceOptions{}
+	}
+	opts = opts.copy()
+	serviceOpts = serviceOpts.copy()
+	if opts.MaxRestarts == 0 {
+		opts.MaxRestarts = 3
+	}
+	if opts.RestartWindow == 0 {
+		opts.RestartWindow = time.Minute
+	}
+	s := &Supervisor{
+		factory:        factory,
+		supervisorOpts: opts,
+		stopping:       make(chan struct{}),
+		started:        make(chan struct{}),
+	}
+	probe := serviceOpts.readinessProbe()
+	serviceOpts.ReadinessProbe = nil
+	serviceOpts.ReadinessProbeCtx = func(ctx context.Context) <-chan error {
+		return s.probe(ctx, probe)
+	}
+	s.Worker = NewWorkerWithOptions(&Hooks{
+		Name:      name,
+		Start:     s.start,
+		Shutdown:  s.shutdown,
+		Terminate: s.terminate,
+	}, serviceOpts)
+	return s
+}
+
+// Current returns the current instance of the supervised service, or nil if
+// none was created yet.
+func (s *Supervisor) Current() Service {
+	s.supervisorMut.Lock()
+	defer s.supervisorMut.Unlock()
+	return s.current
+}
+
+// Reset re-arms the supervisor, so that it can be started again. A new
+// instance of the supervised service is created when the supervisor is
+// started. See Worker.Reset.
+func (s *Supervisor) Reset() error {
+	return s.ResetCtx(context.Background())
+}
+
+// ResetCtx re-arms the supervisor providing context. See Worker.ResetCtx.
+func (s *Supervisor) ResetCtx(ctx context.Context) error {
+	if err := s.Worker.ResetCtx(ctx); err != nil {
+		return err
+	}
+	s.supervisorMut.Lock()
+	defer s.supervisorMut.Unlock()
+	s.current = nil
+	s.stopping = make(chan struct{})
+	s.started = make(chan struct{})
+	s.startedOnce = sync.Once{}
+	return nil
+}
+
+// start runs instances of the supervised service until the restart policy
+// does not require a restart anymore, or the restart limit is exceeded.
+func (s *Supervisor) start(ctx context.Context) error {
+	// Instances are shut down by the supervisor, so they must neither be
+	// cancelled along with the run context of the supervisor nor handle
+	// signals themselves.
+	ctx = withManaged(detach(ctx))
+
+	var restarts []time.Time
+	for {
+		service := s.factory()
+		if service == nil {
+			return fmt.Errorf("service factory returned nil")
+		}
+		if !s.setCurrent(service) {
+			return nil
+		}
+
+		err := s.run(ctx, service)
+		select {
+		case <-s.stopping:
+			return nil
+		default:
+		}
+
+		failed := err != nil || service.State() == Error
+		switch s.supervisorOpts.Policy {
+		case RestartNever:
+			return err
+		case RestartOnFailure:
+			if !failed {
+				return nil
+			}
+		}
+
+		// Only count the restarts within the restart window
+		now := time.Now()
+		for len(restarts) > 0 &&
+			now.Sub(restarts[0]) > s.supervisorOpts.RestartWindow {
+			restarts = restarts[1:]
+		}
+		if s.supervisorOpts.MaxRestarts >= 0 &&
+			len(restarts) >= s.supervisorOpts.MaxRestarts {
+			if err == nil {
+				err = fmt.Errorf("service %s stopped", service.Name())
+			}
+			return fmt.Errorf("service %s restarted more than %d times in %s: %w",
+				service.Name(), s.supervisorOpts.MaxRestarts,
+				s.supervisorOpts.RestartWindow, err)
+		}
+
+		delay := s.supervisorOpts.Backoff.Delay(len(restarts))
+		restarts = append(restarts, now)
+		s.info("restarting service", "delay", delay, "failed", failed)
+		select {
+		case <-time.After(delay):
+		case <-s.stopping:
+			return nil
+		}
+	}
+}
+
+// run starts the provided instance of the supervised service and blocks until
+// it is done. It returns the last error posted by the service to its observers,
+// such as the error that caused it to transition to an Error state or a
+// liveness probe failure.
+func (s *Supervisor) run(ctx context.Context, service Service) error {
+	if state := service.State(); state != Initial {
+		return fmt.Errorf("cannot start service %s from %s: %w",
+			service.Name(), state.String(), errInvalidState)
+	}
+
+	// Observe the service to collect its errors ; the chan is closed when the
+	// service reaches a final state.
+	var lastErr error
+	events := make(chan Event)
+	observed := make(chan struct{})
+	service.Observe(events)
+	go func() {
+		defer close(observed)
+		for event := range events {
+			if event.Error != nil {
+				lastErr = event.Error
+			}
+		}
+	}()
+
+	// The supervisor may have been stopped since the service was made current,
+	// in which case it was not shut down as it was not started yet.
+	if s.isStopping() {
+		service.Unobserve(events)
+		close(events)
+		<-observed
+		return nil
+	}
+
+	// The service is only considered ready if it is not done yet, as the
+	// readiness probe is interrupted when the Start hook returns early.
+	err := service.StartBackgroundCtx(ctx)
+	if s.isStopping() {
+		// The supervisor was stopped while the service was starting
+		if err := service.ShutdownCtx(context.Background()); err != nil &&
+			!IsInvalidState(err) {
+			s.error(err, "failed to shut down service")
+		}
+	}
+	if err == nil {
+		select {
+		case <-service.Done():
+		default:
+			s.startedOnce.Do(func() {
+				close(s.started)
+			})
+		}
+	}
+	<-observed
+	return lastErr
+}
+
+// shutdown gracefully shuts the current instance of the supervised service
+// down, and prevents further restarts.
+func (s *Supervisor) shutdown(ctx context.Context) error {
+	if service := s.stop(); service != nil {
+		if err := service.ShutdownCtx(ctx); err != nil && !IsInvalidState(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// terminate terminates the current instance of the supervised service, and
+// prevents further restarts.
+func (s *Supervisor) terminate(ctx context.Context) error {
+	if service := s.stop(); service != nil {
+		if err := service.TerminateCtx(ctx); err != nil && !IsInvalidState(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// setCurrent sets the current instance of the supervised service. It returns
+// false if the supervisor is being stopped, in which case the service must not
+// be started.
+func (s *Supervisor) setCurrent(service Service) bool {
+	s.supervisorMut.Lock()
+	defer s.supervisorMut.Unlock()
+	select {
+	case <-s.stopping:
+		return false
+	default:
+	}
+	s.current = service
+	return true
+}
+
+// stop prevents further restarts and returns the current instance of the
+// supervised service.
+func (s *Supervisor) stop() Service {
+	s.supervisorMut.Lock()
+	defer s.supervisorMut.Unlock()
+	select {
+	case <-s.stopping:
+	default:
+		close(s.stopping)
+	}
+	return s.current
+}
+
+// isStopping returns true once the supervisor is shut down or terminated.
+func (s *Supervisor) isStopping() bool {
+	s.supervisorMut.Lock()
+	defer s.supervisorMut.Unlock()
+	select {
+	case <-s.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// probe returns a chan that is closed once the first instance of the
+// supervised service is ready and the next probe, if any, succeeded.
+func (s *Supervisor) probe(ctx context.Context,
+	next func(ctx context.Context) <-chan error) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		defer close(ch)
+		select {
+		case <-s.started:
+		case <-s.Done():
+			return
+		case <-ctx.Done():
+			ch <- ctx.Err()
+			return
+		}
+		if next != nil {
+			if err := <-next(ctx); err != nil {
+				ch <- err
+			}
+		}
+	}()
+	return ch
+}
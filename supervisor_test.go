@@ -0,0 +1,163 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupervisorRestartOnFailure(t *testing.T) {
+	var count int32
+	s := newTestSupervisor(&count, 2, &SupervisorOptions{})
+	assert.NoError(t, s.StartBackground())
+	<-s.Ready()
+	assert.Equal(t, Started, s.State())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+	assert.NoError(t, s.Shutdown())
+	<-s.Done()
+	assert.Equal(t, Stopped, s.State())
+	assert.Equal(t, Stopped, s.Current().State())
+}
+
+func TestSupervisorRestartLimit(t *testing.T) {
+	var count int32
+	s := newTestSupervisor(&count, 10, &SupervisorOptions{MaxRestarts: 2})
+	s.StartBackground()
+	<-s.Done()
+	assert.Equal(t, Error, s.State())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+}
+
+func TestSupervisorRestartNever(t *testing.T) {
+	var count int32
+	s := newTestSupervisor(&count, 1, &SupervisorOptions{Policy: RestartNever})
+	s.StartBackground()
+	<-s.Done()
+	assert.Equal(t, Error, s.State())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+}
+
+func TestSupervisorRestartAlways(t *testing.T) {
+	var count int32
+	s := NewSupervisorWithOptions("supervisor", func() Service {
+		n := atomic.AddInt32(&count, 1)
+		return NewWorkerWithOptions(&Hooks{
+			Name: "worker",
+			Start: func(ctx context.Context) error {
+				if n < 3 {
+					return nil
+				}
+				<-ctx.Done()
+				return nil
+			},
+			Shutdown: func(ctx context.Context) error {
+				return nil
+			},
+		}, &ServiceOptions{Signals: []os.Signal{}})
+	}, &SupervisorOptions{
+		Policy:  RestartAlways,
+		Backoff: Backoff{Initial: time.Millisecond},
+	}, &ServiceOptions{Signals: []os.Signal{}})
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, s.StartBackgroundCtx(ctx))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&count) == 3
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, s.Terminate())
+	cancel()
+	<-s.Done()
+	assert.Equal(t, Stopped, s.State())
+}
+
+func TestSupervisorShutdownBeforeStart(t *testing.T) {
+	var (
+		s    *Supervisor
+		once sync.Once
+	)
+	worker := newTestWorker("worker", 0, time.Second, nil)
+	s = NewSupervisorWithOptions("supervisor", func() Service {
+		// Shut the supervisor down once the instance is current, but before
+		// it is started
+		return &stateHookService{Service: worker, hook: func() {
+			once.Do(func() {
+				go s.Shutdown()
+				for !s.isStopping() {
+					time.Sleep(time.Millisecond)
+				}
+			})
+		}}
+	}, &SupervisorOptions{}, &ServiceOptions{
+		ShutdownTimeout: time.Second,
+		Signals:         []os.Signal{},
+	})
+	s.StartBackground()
+	select {
+	case <-s.Done():
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("supervisor not done")
+	}
+	assert.Equal(t, Stopped, s.State())
+	assert.Equal(t, Initial, worker.State())
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 5 * time.Second}
+	assert.Equal(t, time.Second, b.Delay(0))
+	assert.Equal(t, 2*time.Second, b.Delay(1))
+	assert.Equal(t, 4*time.Second, b.Delay(2))
+	assert.Equal(t, 5*time.Second, b.Delay(3))
+
+	b.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		delay := b.Delay(1)
+		assert.True(t, delay >= time.Second && delay <= 3*time.Second)
+	}
+}
+
+// newTestSupervisor creates a supervisor whose services fail the first
+// failures times they are started.
+func newTestSupervisor(count *int32, failures int32,
+	opts *SupervisorOptions) *Supervisor {
+	opts.Backoff = Backoff{Initial: time.Millisecond}
+	return NewSupervisorWithOptions("supervisor", func() Service {
+		n := atomic.AddInt32(count, 1)
+		stop := make(chan struct{})
+		return NewWorkerWithOptions(&Hooks{
+			Name: "worker",
+			Start: func(ctx context.Context) error {
+				if n <= failures {
+					return errors.New("oops")
+				}
+				<-stop
+				return nil
+			},
+			Shutdown: func(ctx context.Context) error {
+				close(stop)
+				return nil
+			},
+		}, &ServiceOptions{
+			ReadinessProbe: Wait(5 * time.Millisecond),
+			Signals:        []os.Signal{},
+		})
+	}, opts, &ServiceOptions{
+		Logger:  simpleLogger{},
+		Signals: []os.Signal{},
+	})
+}
+
+// stateHookService calls hook whenever the state of the service is read.
+type stateHookService struct {
+	Service
+	hook func()
+}
+
+func (s *stateHookService) State() State {
+	s.hook()
+	return s.Service.State()
+}
@@ -2,6 +2,9 @@ package lifecycle
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"math/rand"
 	"time"
 )
 
@@ -29,3 +32,88 @@ func Wait(duration time.Duration) func() <-chan error {
 		return ch
 	}
 }
+
+// Backoff defines an exponential backoff policy with optional jitter.
+type Backoff struct {
+	// Initial delay (default: 100 milliseconds).
+	Initial time.Duration
+	// Maximum delay (default: 30 seconds).
+	Max time.Duration
+	// Factor by which the delay is multiplied after each attempt (default: 2).
+	Multiplier float64
+	// Jitter randomizes each delay by up to the given fraction of its value,
+	// for example 0.1 for ±10% (default: no jitter).
+	Jitter float64
+}
+
+// Delay returns the amount of time to wait before the provided attempt. The
+// first attempt is 0.
+func (b Backoff) Delay(attempt int) time.Duration {
+	initial, max, multiplier := b.Initial, b.Max, b.Multiplier
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt))
+	if delay > float64(max) {
+		delay = float64(max)
+	}
+	if b.Jitter > 0 {
+		delay += delay * b.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// detachedContext is a context carrying the values of its parent, but which is
+// never cancelled.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+// detach returns a context carrying the values of ctx, but which is not
+// cancelled with it.
+func detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+// callWithTimeout calls hook and returns its error, or a timeout error if it
+// does not return within timeout, in which case the hook is abandoned. The hook
+// is called synchronously if timeout is not positive.
+func callWithTimeout(ctx context.Context, hook ContextHook,
+	timeout time.Duration) error {
+	if timeout <= 0 {
+		return hook(ctx)
+	}
+
+	// The chan is buffered so that an abandoned hook does not leak its
+	// goroutine once it returns.
+	ch := make(chan error, 1)
+	go func() {
+		ch <- hook(ctx)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-ch:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("did not return in %s: %w", timeout, errTimeout)
+	}
+}
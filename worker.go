@@ -2,10 +2,11 @@ package lifecycle
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -22,6 +23,22 @@ type Event struct {
 	From State
 	// The new status of the service.
 	To State
+	// The name of the service, as defined in Hooks.
+	Name string
+	// Sequence number of the event. Events posted by a service have strictly
+	// increasing sequence numbers, starting at 1.
+	Seq uint64
+	// The time at which the event occurred.
+	Time time.Time
+	// The amount of time spent by the service in the From state.
+	Duration time.Duration
+	// A human-readable reason for the event, if any, for example the signal
+	// which caused the service to shut down.
+	Reason string
+	// The phase hook which was running when the event occurred, if any.
+	// Observers are notified with an event carrying the phase, without
+	// state change, when a phase hook is called.
+	Phase Phase
 }
 
 // Hooks contain the functions called by the worker to control the underlying
@@ -33,7 +50,8 @@ type Hooks struct {
 	// is running. Returning from this function will cause the service to
 	// transition to Stopped if no error is returned or if the returned error is
 	// ignored by the Error hook. In other cases, the service will transition to
-	// an Error state.
+	// an Error state. The context provided to this function is cancelled when
+	// the service is shut down or terminated, see Worker.Context.
 	Start ContextHook
 	// Gracefully shuts down the service. This function is expected to block
 	// until the service is shut down. Returning an error from this function
@@ -49,6 +67,33 @@ type Hooks struct {
 	// hook. In this case, the server will remain in a Terminating state until
 	// it is either terminated, or the Start hook returns.
 	Terminate ContextHook
+	// Reloads the service, typically its configuration (optional). The
+	// service transitions to Reloading while this function is running, and
+	// back to Started once it returns. Returning an error from this function
+	// will cause the service to transition to an Error state, unless the
+	// error is ignored by the Error hook.
+	Reload ContextHook
+	// Pauses the service, for example to stop pulling work during a
+	// downstream outage without tearing down connections (optional). The
+	// service transitions to Paused before this function is called. Returning
+	// an error from this function will cause the service to transition to an
+	// Error state, unless the error is ignored by the Error hook.
+	Pause ContextHook
+	// Resumes a paused service (optional). The service transitions back to
+	// Started before this function is called, or without calling any
+	// function if it is not defined. Returning an error from this function
+	// will cause the service to transition to an Error state, unless the
+	// error is ignored by the Error hook.
+	Resume ContextHook
+	// PreStart, PostStart, PreStop and PostStop are optional hooks run around
+	// the main hooks of the service, see Phase. Returning an error from these
+	// functions will cause the service to transition to an Error state,
+	// unless the error is ignored by the Error hook. The error identifies
+	// the phase in which it occurred.
+	PreStart  ContextHook
+	PostStart ContextHook
+	PreStop   ContextHook
+	PostStop  ContextHook
 	// Error receives error events. The event struct contains the context
 	// passed to the hook from which it occurred, as well as the error itself.
 	// The error returned by this function will be passed to the caller. If nil
@@ -69,10 +114,48 @@ type ServiceOptions struct {
 	// an error. In the latter case, the service will transition to an Error
 	// state, unless the error is ignored by the Error hook.
 	ReadinessProbe func() <-chan error
+	// ReadinessProbeCtx is a context-aware alternative to ReadinessProbe. The
+	// context provided to the probe derives from the run context of the
+	// service, see Worker.Context, and is cancelled once the wait for
+	// readiness is over. If both probes are defined, ReadinessProbe is
+	// ignored.
+	ReadinessProbeCtx func(ctx context.Context) <-chan error
+	// ReadyNotify requires the Start hook to signal that the service is ready
+	// by calling NotifyReady with the context it received. If a readiness
+	// probe is also defined, it is executed once the notification is
+	// received.
+	ReadyNotify bool
+	// LivenessProbe defines a check periodically executed while the service
+	// is Started, and the action to be taken when it keeps failing. See
+	// LivenessProbe for details.
+	LivenessProbe *LivenessProbe
 	// ShutdownTimeout defines a maximum amount of time for which the service
 	// can remain in ShuttingDown state. When the specified amount of time
 	// is elapsed, the service is terminated (default: 15 seconds).
 	ShutdownTimeout time.Duration
+	// StartTimeout defines a maximum amount of time for the service to become
+	// ready. When the specified amount of time is elapsed, the service
+	// transitions to an Error state with a timeout error, see IsTimeout
+	// (default: 0, no timeout).
+	StartTimeout time.Duration
+	// TerminateTimeout defines a maximum amount of time for the Terminate
+	// hook to return. When the specified amount of time is elapsed, the hook
+	// is abandoned and the service transitions to an Error state with a
+	// timeout error, or to Stopped if the error is ignored by the Error hook
+	// (default: 0, no timeout).
+	TerminateTimeout time.Duration
+	// PhaseTimeouts defines a maximum amount of time for each of the phase
+	// hooks. When the specified amount of time is elapsed, the hook is
+	// abandoned and handled as failed with a timeout error (default: no
+	// timeout).
+	PhaseTimeouts map[Phase]time.Duration
+	// PreStopDelay defines an amount of time for which the service keeps
+	// running in a Draining state when it is shut down, before the Shutdown
+	// hook is called. During this time, the service is reported as not ready
+	// by the health handler, for example to let a load balancer remove it from
+	// its endpoints. ShutdownTimeout applies once the delay is elapsed
+	// (default: 0, no draining).
+	PreStopDelay time.Duration
 	// Signals defines the signals to listen to. When one of these signals is
 	// received, the action defined by SignalAction will be taken (default:
 	// syscall.SIGINT, syscall.SIGTERM).
@@ -80,6 +163,31 @@ type ServiceOptions struct {
 	// Defines the action to be taken when a signal is received (default:
 	// Shutdown)
 	SignalAction Action
+	// ReloadSignals defines the signals upon which the service is reloaded
+	// (default: syscall.SIGHUP if the Reload hook is defined).
+	ReloadSignals []os.Signal
+	// SignalActions binds signals to actions, overriding the actions defined
+	// by Signals, SignalAction and ReloadSignals for the same signals. The
+	// DoNothing action allows to ignore a signal.
+	SignalActions map[os.Signal]Action
+	// ForceExitCode enables forced exits: when set, a third signal bound to
+	// the Shutdown or Terminate action exits the process with this code. The
+	// first signal triggers the bound action, and the second one escalates a
+	// graceful shutdown to a termination (default: 0, disabled).
+	ForceExitCode int
+	// SignalHandlers binds signals to custom handlers, for example DumpState
+	// or DumpGoroutines. If a signal is bound to both a handler and an action,
+	// the handler is called before the action is taken.
+	SignalHandlers map[os.Signal]SignalHandler
+	// SignalRouter is the router the service registers with to receive
+	// signals. Services sharing a router handle each signal in the order in
+	// which they were started (default: DefaultSignalRouter).
+	SignalRouter *SignalRouter
+	// CancelAction defines the action to be taken when the context the
+	// service is started with is cancelled, either Shutdown, Terminate or
+	// DoNothing. The cancellation is recorded as the reason of the resulting
+	// events (default: Shutdown).
+	CancelAction Action
 	// Sets the Logger to use to log worker events. If nil, the logging messages
 	// are discarded.
 	Logger Logger
@@ -92,22 +200,57 @@ func (o ServiceOptions) copy() *ServiceOptions {
 // Worker is a Service that can be started, stopped and terminated based on a
 // set of provided hooks.
 type Worker struct {
+	// Number of events dropped by observers ; first field to guarantee 64-bit
+	// alignment for atomic operations
+	dropped uint64
 	// Service hooksification
 	hooks *Hooks
 	// Service options
 	opts *ServiceOptions
 	// Current state
 	state State
+	// Time at which the current state was entered
+	since time.Time
+	// Sequence number of the last event
+	seq uint64
 	// Enforces atomic state change
 	mut sync.Mutex
-	// Prevent against double close of the done chan
-	unlockOnce sync.Once
+	// Closes the done chan of the current run, once
+	unblock func()
 	// Closed when we are ready
 	ready chan struct{}
 	// Closed when we are done
 	done chan struct{}
+	// Closed when the Start hook has returned
+	exited chan struct{}
+	// Last error reported by the service
+	err error
+	// Orders the delivery of events to observers, which happens outside of
+	// mut so that slow observers do not block State callers
+	dispatchMut sync.Mutex
+	// Signaled once an event was delivered to the observers
+	dispatchCond *sync.Cond
+	// Sequence number of the last event delivered to the observers
+	delivered uint64
+	// Protects observers, so that they can be removed while an event is
+	// being delivered to them
+	observersMut sync.Mutex
 	// Observers
-	observers []chan<- Event
+	observers []*subscription
+	// Runs the PostStop hook once per run
+	postStopOnce sync.Once
+	// Goroutines started with Go
+	goroutines sync.WaitGroup
+	// Context of the current run, cancelled on shutdown
+	runCtx context.Context
+	// Cancels the context of the current run
+	runCancel context.CancelFunc
+	// Callbacks registered with OnTransition
+	callbacks []*callback
+	// Chan on which events are delivered to callbacks, nil without callbacks
+	callbackCh chan Event
+	// Closed when callbacks are not delivered events anymore
+	callbackStop chan struct{}
 }
 
 // NewWorker creates a Worker with the provided hooks. It returns nil if either
@@ -137,13 +280,49 @@ func NewWorkerWithOptions(hooks *Hooks, opts *ServiceOptions) *Worker {
 	if opts.SignalAction == Undefined {
 		opts.SignalAction = Shutdown
 	}
-	return &Worker{
-		hooks: hooks,
-		opts:  opts,
-		state: Initial,
-		ready: make(chan struct{}),
-		done:  make(chan struct{}),
+	if opts.ReloadSignals == nil && hooks.Reload != nil {
+		opts.ReloadSignals = []os.Signal{syscall.SIGHUP}
+	}
+	opts.ReadinessProbeCtx = opts.readinessProbe()
+	if opts.CancelAction == Undefined {
+		opts.CancelAction = Shutdown
+	}
+	opts.SignalActions = signalActions(opts)
+	if opts.SignalRouter == nil {
+		opts.SignalRouter = DefaultSignalRouter
+	}
+	if opts.LivenessProbe != nil {
+		opts.LivenessProbe = opts.LivenessProbe.copy()
+		if opts.LivenessProbe.Check == nil {
+			opts.LivenessProbe = nil
+		}
+	}
+	if probe := opts.LivenessProbe; probe != nil {
+		if probe.Interval == 0 {
+			probe.Interval = 10 * time.Second
+		}
+		if probe.Timeout == 0 {
+			probe.Timeout = time.Second
+		}
+		if probe.FailureThreshold == 0 {
+			probe.FailureThreshold = 3
+		}
+		if probe.Action == Undefined {
+			probe.Action = Shutdown
+		}
+	}
+	c := &Worker{
+		hooks:  hooks,
+		opts:   opts,
+		state:  Initial,
+		since:  time.Now(),
+		ready:  make(chan struct{}),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
 	}
+	c.unblock = closeOnce(c.done)
+	c.dispatchCond = sync.NewCond(&c.dispatchMut)
+	return c
 }
 
 // Start the service. This function blocks until the service is stopped. This
@@ -185,60 +364,77 @@ func (c *Worker) StartBackgroundCtx(ctx context.Context) error {
 		return err
 	}
 
+	// The chans of the current run are only replaced by Reset, which cannot
+	// happen before this run reaches a final state and its Start hook exited.
+	c.mut.Lock()
+	readyCh, done, exited, unblock := c.ready, c.done, c.exited, c.unblock
+	c.mut.Unlock()
+
+	// Derive the run context, provided to the Start hook along with a ready
+	// notifier if required. The cancellation of ctx is handled by the cancel
+	// action instead.
+	runCtx, cancel := context.WithCancel(detach(ctx))
+	c.mut.Lock()
+	c.runCtx, c.runCancel = runCtx, cancel
+	c.mut.Unlock()
+	hookCtx := runCtx
+	var notified <-chan struct{}
+	if c.opts.ReadyNotify {
+		hookCtx, notified = withReadyNotifier(runCtx)
+	}
+
+	// Run the PreStart hook ; the Start hook is not run if it fails
+	if err := c.runPhase(runCtx, PreStart, c.hooks.PreStart); err != nil {
+		close(exited)
+		return err
+	}
+
 	// Start service
 	go func() {
-		defer c.unblockWaiters()
+		// The exited chan is closed last, so that the service cannot be reset
+		// before the waiters of this run are unblocked.
+		defer close(exited)
+		defer unblock()
 
 		var err error
 		if c.hooks.Start != nil {
-			err = c.hooks.Start(ctx)
+			err = c.hooks.Start(hookCtx)
 		}
 		if err != nil {
 			c.handleError(ctx, err)
 		}
 
+		// Cancel the run context and wait for the tracked goroutines to return
+		c.cancelRun()
+		c.goroutines.Wait()
+		c.runPostStop(ctx)
+
 		// Transition to Stopped ; exclude error state in case it was set
 		// already by handleError above.
 		c.transition(ctx, Stopped,
-			[]State{Starting, Started, ShuttingDown, Terminating}, nil)
+			[]State{Starting, Started, Reloading, Paused, Draining,
+				ShuttingDown, Terminating},
+			nil)
 	}()
 
-	// Execute readiness probe
-	ready := make(chan error)
+	// Wait for readiness
+	ready := make(chan error, 1)
 	go func() {
 		defer close(ready)
-		if c.opts.ReadinessProbe != nil {
-			c.info("waiting for readiness")
-			select {
-			case err := <-c.opts.ReadinessProbe():
-				ready <- err
-			case <-c.done:
-				c.info("interrupting readiness probe")
-			}
+		if err := c.waitReady(runCtx, notified, done); err != nil {
+			ready <- err
 		}
 	}()
 
-	// Install signal handlers
-	if len(c.opts.Signals) > 0 {
-		go func() {
-			sc := make(chan os.Signal, 1)
-			signal.Notify(sc, c.opts.Signals...)
-
-			// Wait for a signal to show up or for the server to terminate
-			select {
-			case sig := <-sc:
-				c.info("received signal", "signal", sig)
-				if c.opts.SignalAction == Shutdown {
-					go c.handleError(ctx, c.Shutdown())
-				} else {
-					go c.handleError(ctx, c.Terminate())
-				}
-			case <-c.done:
-			}
+	// Watch the cancellation of ctx
+	if ctx.Done() != nil && c.opts.CancelAction != DoNothing {
+		go c.watchContext(ctx, done)
+	}
 
-			// Uninstall signal handlers
-			signal.Stop(sc)
-		}()
+	// Install signal handlers, unless the signals are handled by a parent
+	// service
+	if signals := c.signals(); len(signals) > 0 && !isManaged(ctx) {
+		c.handleSignals(ctx, signals, done)
 	}
 
 	// Wait for the service to be ready ; the readiness probe wait is
@@ -248,12 +444,18 @@ func (c *Worker) StartBackgroundCtx(ctx context.Context) error {
 	if err != nil {
 		return c.handleError(ctx, err)
 	}
-	close(c.ready)
+	close(readyCh)
 
 	// Transition to Started
 	c.transition(ctx, Started, []State{Starting}, nil)
 
-	return nil
+	// Start liveness checks
+	if c.opts.LivenessProbe != nil {
+		go c.checkLiveness(runCtx, done)
+	}
+
+	// Run the PostStart hook
+	return c.runPhase(runCtx, PostStart, c.hooks.PostStart)
 }
 
 // Shutdown shuts the service down gracefully. This function returns a non-nil
@@ -267,23 +469,51 @@ func (c *Worker) Shutdown() error {
 // function returns a non-nil error if the Shutdown hook returns an error,
 // unless the error is ignored by the Error hook.
 func (c *Worker) ShutdownCtx(ctx context.Context) error {
-	// Transition to stopping
-	if _, err := c.transition(ctx, ShuttingDown,
-		[]State{Starting, Started}, nil); err != nil {
+	// Transition to draining if a pre-stop delay is defined, or to stopping
+	to := ShuttingDown
+	if c.opts.PreStopDelay > 0 {
+		to = Draining
+	}
+	if _, err := c.transition(ctx, to,
+		[]State{Starting, Started, Reloading, Paused}, nil); err != nil {
+		return err
+	}
+
+	// Run the PreStop hook
+	if err := c.runPhase(ctx, PreStop, c.hooks.PreStop); err != nil {
 		return err
 	}
 
+	// Drain the service before shutting it down
+	if to == Draining {
+		c.info("draining service", "delay", c.opts.PreStopDelay)
+		select {
+		case <-time.After(c.opts.PreStopDelay):
+		case <-c.Done():
+			return nil
+		}
+		if _, err := c.transition(ctx, ShuttingDown,
+			[]State{Draining}, nil); err != nil {
+			return err
+		}
+	}
+
 	// Gracefully shutdown the service
+	c.cancelRun()
 	ctx, cancel := context.WithTimeout(ctx, c.opts.ShutdownTimeout)
 	defer cancel()
 	gracefulTermination := make(chan error)
 	go func() {
 		c.info("starting graceful shutdown", "timeout", c.opts.ShutdownTimeout)
+		var err error
 		if c.hooks.Shutdown != nil {
-			err := c.hooks.Shutdown(ctx)
-			if err != nil {
-				gracefulTermination <- err
-			}
+			err = c.hooks.Shutdown(ctx)
+		}
+		if err != nil {
+			gracefulTermination <- err
+		} else {
+			// Wait for the tracked goroutines to return
+			c.goroutines.Wait()
 		}
 		close(gracefulTermination)
 	}()
@@ -293,13 +523,16 @@ func (c *Worker) ShutdownCtx(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
 		c.info("service did not terminate in time -- terminating")
-		return c.TerminateCtx(ctx)
+		return c.TerminateCtx(withReason(ctx, reasonShutdownTimeout))
 	case err = <-gracefulTermination:
 	}
 
 	if err != nil {
 		return c.handleError(ctx, err)
 	}
+	if err = c.runPostStop(ctx); err != nil {
+		return err
+	}
 
 	// Transition to Started
 	c.transition(ctx, Stopped, []State{ShuttingDown}, nil)
@@ -320,24 +553,192 @@ func (c *Worker) Terminate() error {
 func (c *Worker) TerminateCtx(ctx context.Context) error {
 	// Transition to stopping
 	if _, err := c.transition(ctx, Terminating,
-		[]State{Starting, Started, ShuttingDown}, nil); err != nil {
+		[]State{Starting, Started, Reloading, Paused, Draining,
+			ShuttingDown}, nil); err != nil {
 		return err
 	}
 
 	var err error
 	if c.hooks.Terminate != nil {
-		err = c.hooks.Terminate(ctx)
+		err = c.terminate(ctx)
 	}
 
+	// Hook errors leave the service in a Terminating state when ignored,
+	// while a timed out hook is abandoned and the service forcefully stopped.
 	if err != nil {
-		return c.handleError(ctx, err)
+		timeout := IsTimeout(err)
+		if err = c.handleError(ctx, err); err != nil || !timeout {
+			return err
+		}
+	}
+
+	if err = c.runPostStop(ctx); err != nil {
+		return err
 	}
 
-	// Transition to stopped
+	// Transition to stopped, which unblocks the waiters
 	c.transition(ctx, Stopped, []State{Terminating}, nil)
 
-	// Unblock the waiters
-	c.unblockWaiters()
+	return nil
+}
+
+// terminate calls the Terminate hook, and returns a timeout error if it does
+// not return within TerminateTimeout. In this case, the hook goroutine is
+// abandoned.
+func (c *Worker) terminate(ctx context.Context) error {
+	err := callWithTimeout(ctx, c.hooks.Terminate, c.opts.TerminateTimeout)
+	if IsTimeout(err) {
+		c.info("terminate hook did not return in time -- abandoning",
+			"timeout", c.opts.TerminateTimeout)
+		return fmt.Errorf("terminate hook %w", err)
+	}
+	return err
+}
+
+// Reload reloads the service. This function returns a non-nil error if the
+// Reload hook returns an error, unless the error is ignored by the Error hook.
+// It returns an unsupported operation error if the Reload hook is not defined.
+func (c *Worker) Reload() error {
+	return c.ReloadCtx(context.Background())
+}
+
+// ReloadCtx reloads the service providing context. This function returns a
+// non-nil error if the Reload hook returns an error, unless the error is
+// ignored by the Error hook. It returns an unsupported operation error if the
+// Reload hook is not defined.
+func (c *Worker) ReloadCtx(ctx context.Context) error {
+	if c.hooks.Reload == nil {
+		return fmt.Errorf("reload hook is not defined: %w", errUnsupported)
+	}
+
+	// Transition to reloading
+	if _, err := c.transition(ctx, Reloading,
+		[]State{Started}, nil); err != nil {
+		return err
+	}
+
+	c.info("reloading service")
+	if err := c.hooks.Reload(ctx); err != nil {
+		if err = c.handleError(ctx, err); err != nil {
+			return err
+		}
+	}
+
+	// Transition back to Started
+	c.transition(ctx, Started, []State{Reloading}, nil)
+
+	return nil
+}
+
+// Pause pauses the service. This function returns a non-nil error if the Pause
+// hook returns an error, unless the error is ignored by the Error hook. It
+// returns an unsupported operation error if the Pause hook is not defined.
+func (c *Worker) Pause() error {
+	return c.PauseCtx(context.Background())
+}
+
+// PauseCtx pauses the service providing context. This function returns a
+// non-nil error if the Pause hook returns an error, unless the error is ignored
+// by the Error hook. It returns an unsupported operation error if the Pause
+// hook is not defined.
+func (c *Worker) PauseCtx(ctx context.Context) error {
+	if c.hooks.Pause == nil {
+		return fmt.Errorf("pause hook is not defined: %w", errUnsupported)
+	}
+
+	// Transition to paused
+	if _, err := c.transition(ctx, Paused,
+		[]State{Started}, nil); err != nil {
+		return err
+	}
+
+	c.info("pausing service")
+	if err := c.hooks.Pause(ctx); err != nil {
+		return c.handleError(ctx, err)
+	}
+
+	return nil
+}
+
+// Resume resumes a paused service. This function returns a non-nil error if
+// the Resume hook returns an error, unless the error is ignored by the Error
+// hook. If the Resume hook is not defined, the service only transitions back
+// to Started.
+func (c *Worker) Resume() error {
+	return c.ResumeCtx(context.Background())
+}
+
+// ResumeCtx resumes a paused service providing context. This function returns
+// a non-nil error if the Resume hook returns an error, unless the error is
+// ignored by the Error hook. If the Resume hook is not defined, the service
+// only transitions back to Started.
+func (c *Worker) ResumeCtx(ctx context.Context) error {
+	// Transition back to Started
+	if _, err := c.transition(ctx, Started,
+		[]State{Paused}, nil); err != nil {
+		return err
+	}
+
+	if c.hooks.Resume == nil {
+		return nil
+	}
+	c.info("resuming service")
+	if err := c.hooks.Resume(ctx); err != nil {
+		return c.handleError(ctx, err)
+	}
+
+	return nil
+}
+
+// Reset re-arms a service that is either stopped or has transitioned to an
+// Error state, so that it can be started again. The service transitions back
+// to its Initial state, and the chans returned by Ready and Done are replaced.
+// Observers whose chan was closed when the service reached a final state must
+// be registered again, while callbacks and observers registered with KeepOpen
+// are kept. This function returns an invalid state error if the service is not
+// stopped or in an Error state. As the Start hook of a terminated service may
+// still be running once Done is closed, this function blocks until it returns.
+func (c *Worker) Reset() error {
+	return c.ResetCtx(context.Background())
+}
+
+// ResetCtx re-arms a service that is either stopped or has transitioned to an
+// Error state providing context. It returns the error of ctx if it is done
+// before the Start hook returned. See Reset.
+func (c *Worker) ResetCtx(ctx context.Context) error {
+	c.mut.Lock()
+	if !c.isStateOneOf([]State{Stopped, Error}) {
+		defer c.mut.Unlock()
+		return fmt.Errorf("cannot reset from %s: %w", c.state.String(),
+			errInvalidState)
+	}
+	exited := c.exited
+	c.mut.Unlock()
+
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		return fmt.Errorf("start hook did not return: %w", ctx.Err())
+	}
+
+	// The service may have been reset concurrently in the meantime
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if !c.isStateOneOf([]State{Stopped, Error}) || c.exited != exited {
+		return fmt.Errorf("cannot reset from %s: %w", c.state.String(),
+			errInvalidState)
+	}
+
+	c.info("resetting service", "from", c.state.String())
+	c.state = Initial
+	c.since = time.Now()
+	c.ready = make(chan struct{})
+	c.done = make(chan struct{})
+	c.exited = make(chan struct{})
+	c.unblock = closeOnce(c.done)
+	c.err = nil
+	c.runCtx, c.runCancel = nil, nil
+	c.postStopOnce = sync.Once{}
 
 	return nil
 }
@@ -345,15 +746,63 @@ func (c *Worker) TerminateCtx(ctx context.Context) error {
 // Done returns a chan that is closed when the service is either stopped or has
 // transitioned to an Error state.
 func (c *Worker) Done() <-chan struct{} {
+	c.mut.Lock()
+	defer c.mut.Unlock()
 	return c.done
 }
 
 // Ready returns a chan that is closed when the service is either started or
 // has transitioned to an Error state.
 func (c *Worker) Ready() <-chan struct{} {
+	c.mut.Lock()
+	defer c.mut.Unlock()
 	return c.ready
 }
 
+// Context returns the context of the current run of the service. It carries the
+// values of the context the service is started with, and is cancelled when the
+// Shutdown hook is called, or when the service is terminated, stopped or
+// transitions to an Error state.
+// It is also the context provided to the Start hook, and can be used to tie
+// goroutines to the lifetime of the service. This function returns
+// context.Background() if the service was not started yet.
+func (c *Worker) Context() context.Context {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.runCtx == nil {
+		return context.Background()
+	}
+	return c.runCtx
+}
+
+// Go runs fn in a goroutine tracked by the service. The context provided to fn
+// is the run context of the service, see Context. Graceful shutdowns wait for
+// the tracked goroutines to return after the Shutdown hook, within
+// ShutdownTimeout, and the service only transitions to Stopped once they
+// returned. The errors returned by fn are handled as errors of the service,
+// unless the run context is cancelled and the error is the cancellation
+// error. This function returns an invalid state error if the service is not
+// running, or is being shut down.
+func (c *Worker) Go(fn func(ctx context.Context) error) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.runCtx == nil || c.runCtx.Err() != nil || c.state == ShuttingDown {
+		return fmt.Errorf("cannot start goroutine from %s: %w",
+			c.state.String(), errInvalidState)
+	}
+
+	ctx := c.runCtx
+	c.goroutines.Add(1)
+	go func() {
+		defer c.goroutines.Done()
+		err := fn(ctx)
+		if err != nil && !errors.Is(err, ctx.Err()) {
+			c.handleError(ctx, err)
+		}
+	}()
+	return nil
+}
+
 // Name provides a user-friendly name for the service, that is used in
 // the logs.
 func (c *Worker) Name() string {
@@ -362,40 +811,81 @@ func (c *Worker) Name() string {
 
 // State returns the current state of the service.
 func (c *Worker) State() State {
+	c.mut.Lock()
+	defer c.mut.Unlock()
 	return c.state
 }
 
+// Err returns the last error reported by the service, if any. Errors ignored
+// by the Error hook are not reported.
+func (c *Worker) Err() error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.err
+}
+
 // Observe registers a chan on which the service will post lifecycle events
-// such as state changes and errors. No action is taken if ch is nil.
+// such as state changes and errors. Events are delivered with the ObserveBlock
+// policy. No action is taken if ch is nil.
 func (c *Worker) Observe(ch chan<- Event) {
+	c.ObserveWithOptions(ch, nil)
+}
+
+// ObserveWithOptions registers a chan on which the service will post lifecycle
+// events, delivered according to the provided options. Unless KeepOpen is set,
+// the chan is closed once the service reaches a final state. No action is
+// taken if ch is nil.
+func (c *Worker) ObserveWithOptions(ch chan<- Event, opts *ObserveOptions) {
 	if ch == nil {
 		return
 	}
-	c.mut.Lock()
-	defer c.mut.Unlock()
-	c.observers = append(c.observers, ch)
+	c.observersMut.Lock()
+	defer c.observersMut.Unlock()
+	c.observers = append(c.observers, newSubscription(ch, opts, &c.dropped))
 }
 
-// Unobserve removes the provided chan from the list of observers. No action is
-// taken if ch is nil or not in the list of observers.
+// Unobserve removes the provided chan from the list of observers. Events
+// buffered for this chan are discarded, and an event being delivered to this
+// chan with the ObserveBlock policy is abandoned. No action is taken if ch is
+// nil or not in the list of observers.
 func (c *Worker) Unobserve(ch chan<- Event) {
-	c.mut.Lock()
-	defer c.mut.Unlock()
+	c.observersMut.Lock()
+	defer c.observersMut.Unlock()
 	for i, o := range c.observers {
-		if o == ch {
+		if o.ch == ch {
+			o.cancel()
 			c.observers = append(c.observers[:i], c.observers[i+1:]...)
 			break
 		}
 	}
 }
 
-// unblockWaiters unlocks the done chan. It is protected by a Once struct to
-// avoid multiple closes, that could happen when terminate is invoked
-// concurrently with shutdown.
-func (c *Worker) unblockWaiters() {
-	c.unlockOnce.Do(func() {
-		close(c.done)
-	})
+// DroppedEvents returns the number of events dropped so far by the observers
+// of the service, according to their ObserveDropOldest or ObserveDropNewest
+// policies.
+func (c *Worker) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
+
+// cancelRun cancels the context of the current run.
+func (c *Worker) cancelRun() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.runCancel != nil {
+		c.runCancel()
+	}
+}
+
+// closeOnce returns a function closing ch, protected by a Once struct to avoid
+// multiple closes, that could happen when terminate is invoked concurrently
+// with shutdown.
+func closeOnce(ch chan struct{}) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(ch)
+		})
+	}
 }
 
 // Transition transitions the service to a new stare. If a non-empty list of
@@ -405,41 +895,155 @@ func (c *Worker) unblockWaiters() {
 func (c *Worker) transition(ctx context.Context, to State,
 	allowedFromStates []State, cause error) (State, error) {
 	c.mut.Lock()
-	defer c.mut.Unlock()
 
 	current := c.state
 	if len(allowedFromStates) > 0 && !c.isStateOneOf(allowedFromStates) {
+		c.mut.Unlock()
 		err := fmt.Errorf("cannot transition from %s to %s: %w",
 			current.String(), to.String(), errInvalidState)
 		return current, err
 	}
 
 	c.state = to
+	// Unblock the waiters on errors and terminations ; a graceful shutdown
+	// unblocks them once the Start hook returned.
+	if to == Error || to == Stopped && current == Terminating {
+		c.unblock()
+	}
+	// A graceful shutdown cancels the run context itself, once the PreStop
+	// hook returned.
+	if c.runCancel != nil && isOneOf(to,
+		[]State{Terminating, Stopped, Error}) {
+		c.runCancel()
+	}
 	if to != current {
 		c.info("transitioned to state", "to", to.String(), "from",
 			current.String())
 	}
 
 	// Notify observers
-	event := Event{
-		From:  current,
-		To:    to,
-		Error: cause,
-	}
+	event := c.newEvent(ctx, current, to, cause)
+	c.since = event.Time
 	isFinalState := cause != nil || to == Stopped || to == Error
-	for _, observer := range c.observers {
-		observer <- event
-		if isFinalState {
-			close(observer)
-		}
-	}
+	c.observersMut.Lock()
+	observers := append([]*subscription(nil), c.observers...)
 	if isFinalState {
+		// Only keep the observers whose chan is not closed
 		c.observers = nil
+		for _, observer := range observers {
+			if observer.opts.KeepOpen {
+				c.observers = append(c.observers, observer)
+			}
+		}
 	}
+	c.observersMut.Unlock()
+	c.dispatch(observers, event, isFinalState)
 
 	return current, nil
 }
 
+// notify posts an event to the observers without changing the state of the
+// service. This function is thread-safe.
+func (c *Worker) notify(ctx context.Context, err error) {
+	c.mut.Lock()
+	if err != nil {
+		c.err = err
+	}
+	event := c.newEvent(ctx, c.state, c.state, err)
+	c.observersMut.Lock()
+	observers := append([]*subscription(nil), c.observers...)
+	c.observersMut.Unlock()
+	c.dispatch(observers, event, false)
+}
+
+// newEvent creates the next event posted to the observers. This function is
+// not thread-safe.
+func (c *Worker) newEvent(ctx context.Context, from State, to State,
+	err error) Event {
+	c.seq++
+	now := time.Now()
+	return Event{
+		Context:  ctx,
+		Error:    err,
+		From:     from,
+		To:       to,
+		Name:     c.hooks.Name,
+		Seq:      c.seq,
+		Time:     now,
+		Duration: now.Sub(c.since),
+		Reason:   reasonFromContext(ctx),
+		Phase:    phaseFromContext(ctx),
+	}
+}
+
+// watchContext takes the CancelAction when ctx is cancelled before the done
+// chan is closed.
+func (c *Worker) watchContext(ctx context.Context, done <-chan struct{}) {
+	select {
+	case <-ctx.Done():
+	case <-done:
+		return
+	}
+
+	actionCtx := withReason(context.Background(), "parent "+ctx.Err().Error())
+	var err error
+	switch c.opts.CancelAction {
+	case Shutdown:
+		c.info("context cancelled -- shutting down", "error", ctx.Err())
+		err = c.ShutdownCtx(actionCtx)
+	case Terminate:
+		c.info("context cancelled -- terminating", "error", ctx.Err())
+		err = c.TerminateCtx(actionCtx)
+	default:
+		return
+	}
+	if !IsInvalidState(err) {
+		c.handleError(actionCtx, err)
+	}
+}
+
+// reasonKey is the context key of the reason of an event.
+type reasonKey struct{}
+
+// reasonShutdownTimeout is the reason of the termination of a service which
+// did not shut down within ShutdownTimeout.
+const reasonShutdownTimeout = "shutdown timeout"
+
+// withReason returns a context carrying the reason of the events it causes.
+func withReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, reasonKey{}, reason)
+}
+
+// reasonFromContext returns the reason carried by ctx, if any.
+func reasonFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	reason, _ := ctx.Value(reasonKey{}).(string)
+	return reason
+}
+
+// dispatch posts an event to the provided observers. It must be called with
+// mut held, right after the event is created, and releases it before waiting
+// for the delivery of the previous events, so that observers are never
+// notified while holding mut. Events are delivered in the order of their
+// sequence numbers.
+func (c *Worker) dispatch(observers []*subscription, event Event,
+	final bool) {
+	c.mut.Unlock()
+
+	c.dispatchMut.Lock()
+	defer c.dispatchMut.Unlock()
+	for c.delivered+1 != event.Seq {
+		c.dispatchCond.Wait()
+	}
+	for _, observer := range observers {
+		observer.post(event, final)
+	}
+	c.delivered = event.Seq
+	c.dispatchCond.Broadcast()
+}
+
 // isStateOneOf checks whether the current state is in the list of provided
 // states. This function is not thread-safe.
 func (c *Worker) isStateOneOf(states []State) bool {
@@ -482,6 +1086,10 @@ func (c *Worker) handleError(ctx context.Context, err error) error {
 			Context: ctx,
 			Error:   err,
 			From:    c.State(),
+			Name:    c.hooks.Name,
+			Time:    time.Now(),
+			Reason:  reasonFromContext(ctx),
+			Phase:   phaseFromContext(ctx),
 		})
 		if err == nil {
 			return nil
@@ -490,10 +1098,14 @@ func (c *Worker) handleError(ctx context.Context, err error) error {
 
 	// Transition to Error state and unblock waiters
 	c.error(err, "received error")
+	c.mut.Lock()
+	c.err = err
+	c.mut.Unlock()
 	if !IsInterrupted(err) {
 		c.transition(ctx, Error,
-			[]State{Starting, Started, ShuttingDown, Terminating}, err)
-		c.unblockWaiters()
+			[]State{Starting, Started, Reloading, Paused, Draining,
+				ShuttingDown, Terminating},
+			err)
 	}
 
 	return err
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
@@ -34,6 +35,38 @@ func TestWorkerShutdownTimeout(t *testing.T) {
 		s.ObserverEventSequence())
 }
 
+func TestWorkerPreStopDelay(t *testing.T) {
+	s := newTestWorker("worker", 0, time.Second, nil)
+	s.opts.PreStopDelay = 50 * time.Millisecond
+	assert.NoError(t, s.doStart())
+	go s.Shutdown()
+	assert.Eventually(t, func() bool {
+		return s.State() == Draining
+	}, time.Second, time.Millisecond)
+	assert.False(t, isReady(s))
+	assert.True(t, isLive(s))
+	assert.Equal(t,
+		[]State{Starting, Started, Draining, ShuttingDown, Stopped},
+		s.ObserverEventSequence())
+}
+
+func TestWorkerPreStopDelayTerminate(t *testing.T) {
+	s := newTestWorker("worker", 0, time.Second, nil)
+	s.opts.PreStopDelay = 10 * time.Second
+	assert.NoError(t, s.doStart())
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- s.Shutdown()
+	}()
+	assert.Eventually(t, func() bool {
+		return s.State() == Draining
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, s.Terminate())
+	assert.NoError(t, <-shutdown)
+	assert.Equal(t, []State{Starting, Started, Draining, Terminating, Stopped},
+		s.ObserverEventSequence())
+}
+
 func TestWorkerTerminate(t *testing.T) {
 	s := newTestWorker("worker", 0, time.Second, nil)
 	assert.NoError(t, s.doStart())
@@ -47,7 +80,7 @@ func TestWorkerSignal(t *testing.T) {
 	s := newTestWorker("worker", 0, time.Second, nil)
 	assert.NoError(t, s.doStart())
 	assert.Equal(t, Started, s.State())
-	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	s.signal(syscall.SIGUSR2)
 	assert.Equal(t, []State{Starting, Started, ShuttingDown, Stopped},
 		s.ObserverEventSequence())
 }
@@ -56,12 +89,65 @@ func TestWorkerSignalShutdownTimeout(t *testing.T) {
 	s := newTestWorker("worker", 10*time.Second, 50*time.Millisecond, nil)
 	assert.NoError(t, s.doStart())
 	assert.Equal(t, Started, s.State())
-	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	s.signal(syscall.SIGUSR2)
 	assert.Equal(t,
 		[]State{Starting, Started, ShuttingDown, Terminating, Stopped},
 		s.ObserverEventSequence())
 }
 
+func TestWorkerStartTimeout(t *testing.T) {
+	s := newTestWorker("worker", 0, time.Second, func() <-chan error {
+		return make(chan error)
+	})
+	s.opts.StartTimeout = 10 * time.Millisecond
+	err := s.StartBackground()
+	assert.True(t, IsTimeout(err))
+	<-s.Done()
+	assert.Equal(t, Error, s.State())
+	assert.True(t, IsTimeout(s.Err()))
+	s.interrupt(nil)
+}
+
+func TestWorkerTerminateTimeout(t *testing.T) {
+	for _, ignore := range []bool{false, true} {
+		stop := make(chan struct{})
+		w := NewWorkerWithOptions(&Hooks{
+			Name: "worker",
+			Start: func(ctx context.Context) error {
+				<-stop
+				return nil
+			},
+			Shutdown: func(ctx context.Context) error {
+				return nil
+			},
+			Terminate: func(ctx context.Context) error {
+				<-stop
+				return nil
+			},
+			Error: func(event Event) error {
+				if ignore {
+					return nil
+				}
+				return event.Error
+			},
+		}, &ServiceOptions{
+			TerminateTimeout: 10 * time.Millisecond,
+			Signals:          []os.Signal{},
+		})
+		assert.NoError(t, w.StartBackground())
+		err := w.Terminate()
+		<-w.Done()
+		if ignore {
+			assert.NoError(t, err)
+			assert.Equal(t, Stopped, w.State())
+		} else {
+			assert.True(t, IsTimeout(err))
+			assert.Equal(t, Error, w.State())
+		}
+		close(stop)
+	}
+}
+
 func TestWorkerExitingNoError(t *testing.T) {
 	s := newTestWorker("worker", 0, time.Second, nil)
 	assert.NoError(t, s.doStart())
@@ -120,6 +206,849 @@ func TestReadinessProbeError(t *testing.T) {
 	assert.Equal(t, []State{Starting, Error}, s.ObserverEventSequence())
 }
 
+func TestReadinessProbeCtx(t *testing.T) {
+	cancelled := make(chan struct{})
+	s := newTestWorker("worker", 0, time.Second, nil)
+	s.opts.ReadinessProbeCtx = func(ctx context.Context) <-chan error {
+		go func() {
+			<-ctx.Done()
+			close(cancelled)
+		}()
+		return make(chan error)
+	}
+	go s.interrupt(nil)
+	assert.NoError(t, s.StartBackground())
+	<-s.Done()
+	<-cancelled
+	assert.Equal(t, Stopped, s.State())
+}
+
+func TestReadyNotify(t *testing.T) {
+	stop := make(chan struct{})
+	notified := make(chan bool, 1)
+	w := NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			notified <- NotifyReady(ctx)
+			NotifyReady(ctx)
+			<-stop
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			close(stop)
+			return nil
+		},
+	}, &ServiceOptions{
+		ReadyNotify: true,
+		Signals:     []os.Signal{},
+	})
+	assert.NoError(t, w.StartBackground())
+	assert.True(t, <-notified)
+	assert.Equal(t, Started, w.State())
+	assert.False(t, NotifyReady(context.Background()))
+	assert.NoError(t, w.Shutdown())
+}
+
+func TestWorkerContext(t *testing.T) {
+	w := NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			return nil
+		},
+	}, &ServiceOptions{Signals: []os.Signal{}})
+	assert.Equal(t, context.Background(), w.Context())
+	assert.NoError(t, w.StartBackground())
+	ctx := w.Context()
+	assert.NoError(t, ctx.Err())
+	assert.NoError(t, w.Shutdown())
+	<-w.Done()
+	assert.Equal(t, context.Canceled, ctx.Err())
+	assert.Equal(t, Stopped, w.State())
+}
+
+func TestWorkerCancelAction(t *testing.T) {
+	s := newTestWorker("worker", 0, time.Second, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, s.StartBackgroundCtx(ctx))
+	cancel()
+	assert.Equal(t, []State{Starting, Started, ShuttingDown, Stopped},
+		s.ObserverEventSequence())
+	assert.Equal(t, "parent context canceled", s.ObserverEvents()[2].Reason)
+
+	s = newTestWorker("worker", 0, time.Second, nil)
+	s.opts.CancelAction = Terminate
+	ctx, cancel = context.WithTimeout(context.Background(),
+		20*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, s.StartBackgroundCtx(ctx))
+	assert.Equal(t, []State{Starting, Started, Terminating, Stopped},
+		s.ObserverEventSequence())
+	assert.Equal(t, "parent context deadline exceeded",
+		s.ObserverEvents()[2].Reason)
+
+	s = newTestWorker("worker", 0, time.Second, nil)
+	s.opts.CancelAction = DoNothing
+	ctx, cancel = context.WithCancel(context.Background())
+	assert.NoError(t, s.StartBackgroundCtx(ctx))
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, Started, s.State())
+	assert.NoError(t, s.Context().Err())
+	assert.NoError(t, s.Shutdown())
+}
+
+func TestWorkerGo(t *testing.T) {
+	var flushed int32
+	s := newTestWorker("worker", 0, time.Second, nil)
+	assert.True(t, IsInvalidState(s.Go(func(ctx context.Context) error {
+		return nil
+	})))
+	assert.NoError(t, s.doStart())
+	assert.NoError(t, s.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		atomic.StoreInt32(&flushed, 1)
+		return ctx.Err()
+	}))
+	assert.NoError(t, s.Shutdown())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&flushed))
+	assert.Equal(t, []State{Starting, Started, ShuttingDown, Stopped},
+		s.ObserverEventSequence())
+	assert.True(t, IsInvalidState(s.Go(func(ctx context.Context) error {
+		return nil
+	})))
+}
+
+func TestWorkerGoError(t *testing.T) {
+	s := newTestWorker("worker", 0, time.Second, nil)
+	assert.NoError(t, s.doStart())
+	s.Go(func(ctx context.Context) error {
+		return errors.New("ignore")
+	})
+	s.Go(func(ctx context.Context) error {
+		return errors.New("oops")
+	})
+	<-s.Done()
+	assert.Equal(t, Error, s.State())
+	assert.EqualError(t, s.Err(), "oops")
+	s.interrupt(nil)
+}
+
+func TestWorkerGoShutdownTimeout(t *testing.T) {
+	s := newTestWorker("worker", 0, 20*time.Millisecond, nil)
+	assert.NoError(t, s.doStart())
+	stop := make(chan struct{})
+	defer close(stop)
+	s.Go(func(ctx context.Context) error {
+		<-stop
+		return nil
+	})
+	assert.NoError(t, s.Shutdown())
+	assert.Equal(t,
+		[]State{Starting, Started, ShuttingDown, Terminating, Stopped},
+		s.ObserverEventSequence())
+}
+
+func TestWorkerPhases(t *testing.T) {
+	var (
+		mut sync.Mutex
+		log []string
+	)
+	record := func(msg string) ContextHook {
+		return func(ctx context.Context) error {
+			mut.Lock()
+			defer mut.Unlock()
+			log = append(log, msg)
+			return nil
+		}
+	}
+	stop := make(chan struct{})
+	var w *Worker
+	w = NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			record("start")(ctx)
+			NotifyReady(ctx)
+			<-stop
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			record("shutdown")(ctx)
+			close(stop)
+			return nil
+		},
+		PreStart:  record("pre-start"),
+		PostStart: record("post-start"),
+		PreStop: func(ctx context.Context) error {
+			// The service is still running
+			assert.NoError(t, w.Context().Err())
+			return record("pre-stop")(ctx)
+		},
+		PostStop: record("post-stop"),
+	}, &ServiceOptions{
+		ReadyNotify: true,
+		Signals:     []os.Signal{},
+	})
+	o := newEventObserver()
+	w.Observe(o.ObserverChan())
+	assert.NoError(t, w.StartBackground())
+	assert.NoError(t, w.Shutdown())
+	<-w.Done()
+	mut.Lock()
+	assert.Equal(t, []string{"pre-start", "start", "post-start", "pre-stop",
+		"shutdown", "post-stop"}, log)
+	mut.Unlock()
+
+	var phases []Phase
+	for _, event := range o.ObserverEvents() {
+		if event.Phase != NoPhase {
+			assert.Equal(t, event.From, event.To)
+			phases = append(phases, event.Phase)
+		}
+	}
+	assert.Equal(t, []Phase{PreStart, PostStart, PreStop, PostStop}, phases)
+}
+
+func TestWorkerPhaseError(t *testing.T) {
+	started := false
+	w := NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			started = true
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			return nil
+		},
+		PreStart: func(ctx context.Context) error {
+			return errors.New("oops")
+		},
+	}, &ServiceOptions{Signals: []os.Signal{}})
+	o := newEventObserver()
+	w.Observe(o.ObserverChan())
+	err := w.StartBackground()
+	assert.EqualError(t, err, "PreStart hook failed: oops")
+	<-w.Done()
+	assert.False(t, started)
+	events := o.ObserverEvents()
+	assert.Equal(t, Error, events[len(events)-1].To)
+	assert.Equal(t, PreStart, events[len(events)-1].Phase)
+	assert.NoError(t, w.Reset())
+}
+
+func TestWorkerPhaseTimeout(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	s := newTestWorker("worker", 0, time.Second, nil)
+	s.hooks.PostStart = func(ctx context.Context) error {
+		<-stop
+		return nil
+	}
+	s.opts.PhaseTimeouts = map[Phase]time.Duration{
+		PostStart: 10 * time.Millisecond,
+	}
+	err := s.StartBackground()
+	assert.True(t, IsTimeout(err))
+	assert.Contains(t, err.Error(), "PostStart hook failed")
+	assert.Equal(t, Error, s.State())
+	s.interrupt(nil)
+}
+
+func TestWorkerReset(t *testing.T) {
+	var stop chan struct{}
+	w := NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			<-stop
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			close(stop)
+			return nil
+		},
+	}, &ServiceOptions{Signals: []os.Signal{}})
+	assert.True(t, IsInvalidState(w.Reset()))
+
+	for i := 0; i < 2; i++ {
+		stop = make(chan struct{})
+		o := newEventObserver()
+		w.Observe(o.ObserverChan())
+		assert.NoError(t, w.StartBackground())
+		<-w.Ready()
+		assert.True(t, IsInvalidState(w.Reset()))
+		assert.NoError(t, w.Shutdown())
+		<-w.Done()
+		assert.Equal(t, []State{Starting, Started, ShuttingDown, Stopped},
+			o.ObserverEventSequence())
+		assert.NoError(t, w.Reset())
+		assert.Equal(t, Initial, w.State())
+	}
+}
+
+func TestWorkerResetAfterTerminate(t *testing.T) {
+	var stop chan struct{}
+	w := NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			<-stop
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			return nil
+		},
+	}, &ServiceOptions{Signals: []os.Signal{}})
+
+	for i := 0; i < 10; i++ {
+		stop = make(chan struct{})
+		assert.NoError(t, w.StartBackground())
+		assert.NoError(t, w.Terminate())
+		<-w.Done()
+		go close(stop)
+		assert.NoError(t, w.Reset())
+		select {
+		case <-w.Done():
+			t.Fatal("done chan of a reset worker is closed")
+		default:
+		}
+	}
+}
+
+func TestWorkerResetTimeout(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	w := NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			<-stop
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			return nil
+		},
+	}, &ServiceOptions{Signals: []os.Signal{}})
+	assert.NoError(t, w.StartBackground())
+	assert.NoError(t, w.Terminate())
+	<-w.Done()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := w.ResetCtx(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, Stopped, w.State())
+}
+
+func TestLivenessProbe(t *testing.T) {
+	var checks int32
+	s := newTestWorker("worker", 0, time.Second, nil)
+	s.opts.LivenessProbe = &LivenessProbe{
+		Check: func(ctx context.Context) error {
+			if atomic.AddInt32(&checks, 1) > 2 {
+				return errors.New("oops")
+			}
+			return nil
+		},
+		Interval:         time.Millisecond,
+		Timeout:          time.Second,
+		FailureThreshold: 2,
+		Action:           Shutdown,
+	}
+	assert.NoError(t, s.doStart())
+	<-s.Done()
+	events := s.ObserverEvents()
+	assert.Equal(t, []State{Starting, Started, Started, ShuttingDown, Stopped},
+		s.ObserverEventSequence())
+	assert.EqualError(t, events[2].Error, "liveness probe failed 2 times: oops")
+	assert.Equal(t, int32(4), atomic.LoadInt32(&checks))
+}
+
+func TestLivenessProbeDuringShutdown(t *testing.T) {
+	checking := make(chan struct{})
+	release := make(chan struct{})
+	s := newTestWorker("worker", 50*time.Millisecond, time.Second, nil)
+	s.opts.LivenessProbe = &LivenessProbe{
+		Check: func(ctx context.Context) error {
+			close(checking)
+			<-release
+			return errors.New("oops")
+		},
+		Interval:         time.Millisecond,
+		Timeout:          time.Second,
+		FailureThreshold: 1,
+		Action:           Shutdown,
+	}
+	assert.NoError(t, s.doStart())
+	<-checking
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- s.Shutdown()
+	}()
+	assert.Eventually(t, func() bool {
+		return s.State() == ShuttingDown
+	}, time.Second, time.Millisecond)
+	close(release)
+	assert.NoError(t, <-shutdown)
+	<-s.Done()
+	assert.Equal(t, Stopped, s.State())
+}
+
+func TestLivenessProbeTimeout(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	s := newTestWorker("worker", 0, time.Second, nil)
+	s.opts.LivenessProbe = &LivenessProbe{
+		Check: func(ctx context.Context) error {
+			<-stop
+			return nil
+		},
+		Interval:         time.Millisecond,
+		Timeout:          10 * time.Millisecond,
+		FailureThreshold: 1,
+		Action:           Shutdown,
+	}
+	assert.NoError(t, s.doStart())
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("liveness check not abandoned")
+	}
+	events := s.ObserverEvents()
+	assert.True(t, IsTimeout(events[2].Error))
+	assert.Equal(t, Stopped, s.State())
+}
+
+func TestLivenessProbeDoNothing(t *testing.T) {
+	s := newTestWorker("worker", 0, time.Second, nil)
+	s.opts.LivenessProbe = &LivenessProbe{
+		Check: func(ctx context.Context) error {
+			return errors.New("oops")
+		},
+		Interval:         time.Millisecond,
+		Timeout:          time.Second,
+		FailureThreshold: 1,
+		Action:           DoNothing,
+	}
+	assert.NoError(t, s.doStart())
+	assert.Equal(t, Started, s.State())
+	assert.NoError(t, s.Shutdown())
+	sequence := s.ObserverEventSequence()
+	assert.True(t, len(sequence) > 4)
+	assert.Equal(t, []State{Starting, Started, Started}, sequence[:3])
+}
+
+func TestObserverPolicies(t *testing.T) {
+	s := newTestWorker("worker", 0, time.Second, nil)
+	blocked := make(chan Event)
+	newest := make(chan Event)
+	oldest := make(chan Event)
+	unbounded := make(chan Event)
+	s.ObserveWithOptions(blocked, &ObserveOptions{
+		Policy: ObserveDropNewest, BufferSize: 1})
+	s.ObserveWithOptions(newest, &ObserveOptions{
+		Policy: ObserveDropNewest, BufferSize: 1})
+	s.ObserveWithOptions(oldest, &ObserveOptions{
+		Policy: ObserveDropOldest, BufferSize: 1})
+	s.ObserveWithOptions(unbounded, &ObserveOptions{
+		Policy: ObserveUnbounded})
+
+	// Observers do not receive events until the worker is stopped, which does
+	// not prevent it from transitioning.
+	assert.NoError(t, s.doStart())
+	assert.Equal(t, Started, s.State())
+	assert.NoError(t, s.Shutdown())
+	assert.Equal(t, []State{Starting, Started, ShuttingDown, Stopped},
+		s.ObserverEventSequence())
+
+	var sequence []State
+	for event := range unbounded {
+		sequence = append(sequence, event.To)
+	}
+	assert.Equal(t, []State{Starting, Started, ShuttingDown, Stopped},
+		sequence)
+	// At most one event is being delivered and one event is buffered
+	sequence = nil
+	for event := range newest {
+		sequence = append(sequence, event.To)
+	}
+	assert.True(t, len(sequence) <= 2)
+	sequence = nil
+	for event := range oldest {
+		sequence = append(sequence, event.To)
+	}
+	assert.True(t, len(sequence) <= 2)
+	assert.Equal(t, Stopped, sequence[len(sequence)-1])
+	assert.True(t, s.DroppedEvents() >= 6)
+	for range blocked {
+	}
+}
+
+func TestObserverUnobserveBlocked(t *testing.T) {
+	s := newTestWorker("worker", 0, time.Second, nil)
+	assert.NoError(t, s.doStart())
+
+	// An observer which never receives events blocks the transitions, but not
+	// the other methods of the worker.
+	ch := make(chan Event)
+	s.Observe(ch)
+	errs := make(chan error, 2)
+	go func() {
+		errs <- s.Shutdown()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		errs <- s.Terminate()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	unobserved := make(chan struct{})
+	go func() {
+		s.Unobserve(ch)
+		<-s.Done()
+		close(unobserved)
+	}()
+	select {
+	case <-unobserved:
+	case <-time.After(time.Second):
+		t.Fatal("worker blocked by observer")
+	}
+	assert.NoError(t, <-errs)
+	assert.NoError(t, <-errs)
+	assert.Equal(t, Stopped, s.State())
+}
+
+func TestWorkerReload(t *testing.T) {
+	var reloads int32
+	router := NewSignalRouter()
+	stop := make(chan struct{})
+	w := NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			<-stop
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			close(stop)
+			return nil
+		},
+		Reload: func(ctx context.Context) error {
+			atomic.AddInt32(&reloads, 1)
+			return nil
+		},
+	}, &ServiceOptions{
+		Signals:       []os.Signal{},
+		ReloadSignals: []os.Signal{syscall.SIGUSR1},
+		SignalRouter:  router,
+	})
+	o := newEventObserver()
+	w.Observe(o.ObserverChan())
+	assert.True(t, IsInvalidState(w.Reload()))
+	assert.NoError(t, w.StartBackground())
+	assert.NoError(t, w.Reload())
+	for i := 0; i < 2; i++ {
+		router.Dispatch(syscall.SIGUSR1)
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&reloads) == int32(i+2)
+		}, time.Second, time.Millisecond)
+	}
+	assert.Eventually(t, func() bool {
+		return w.State() == Started
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, w.Shutdown())
+	assert.Equal(t, []State{Starting, Started, Reloading, Started, Reloading,
+		Started, Reloading, Started, ShuttingDown, Stopped},
+		o.ObserverEventSequence())
+
+	noop := func(ctx context.Context) error { return nil }
+	w = NewWorker(&Hooks{Start: noop, Shutdown: noop})
+	assert.True(t, IsUnsupported(w.Reload()))
+}
+
+func TestWorkerPause(t *testing.T) {
+	var paused int32
+	stop := make(chan struct{})
+	w := NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			<-stop
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			close(stop)
+			return nil
+		},
+		Pause: func(ctx context.Context) error {
+			atomic.StoreInt32(&paused, 1)
+			return nil
+		},
+		Resume: func(ctx context.Context) error {
+			atomic.StoreInt32(&paused, 0)
+			return nil
+		},
+	}, &ServiceOptions{Signals: []os.Signal{}})
+	o := newEventObserver()
+	w.Observe(o.ObserverChan())
+	assert.True(t, IsInvalidState(w.Pause()))
+	assert.NoError(t, w.StartBackground())
+	assert.True(t, IsInvalidState(w.Resume()))
+	assert.NoError(t, w.Pause())
+	assert.Equal(t, Paused, w.State())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&paused))
+	assert.True(t, IsInvalidState(w.Pause()))
+	assert.NoError(t, w.Resume())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&paused))
+	assert.NoError(t, w.Pause())
+	assert.NoError(t, w.Shutdown())
+	assert.Equal(t, []State{Starting, Started, Paused, Started, Paused,
+		ShuttingDown, Stopped}, o.ObserverEventSequence())
+
+	noop := func(ctx context.Context) error { return nil }
+	w = NewWorker(&Hooks{Start: noop, Shutdown: noop})
+	assert.True(t, IsUnsupported(w.Pause()))
+	assert.True(t, IsInvalidState(w.Resume()))
+
+	// Services can be resumed without Resume hook
+	resumed := make(chan struct{})
+	w = NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			<-resumed
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			close(resumed)
+			return nil
+		},
+		Pause: noop,
+	}, &ServiceOptions{Signals: []os.Signal{}})
+	assert.NoError(t, w.StartBackground())
+	assert.NoError(t, w.Pause())
+	assert.NoError(t, w.Resume())
+	assert.Equal(t, Started, w.State())
+	assert.NoError(t, w.Shutdown())
+}
+
+func TestWorkerSignalEscalation(t *testing.T) {
+	s := newTestWorker("worker", 10*time.Second, 10*time.Second, nil)
+	assert.NoError(t, s.doStart())
+	s.signal(syscall.SIGUSR2)
+	assert.Eventually(t, func() bool {
+		return s.State() == ShuttingDown
+	}, time.Second, time.Millisecond)
+	s.signal(syscall.SIGUSR2)
+	events := s.ObserverEvents()
+	assert.Equal(t,
+		[]State{Starting, Started, ShuttingDown, Terminating, Stopped},
+		s.ObserverEventSequence())
+	assert.Equal(t, "signal user defined signal 2", events[2].Reason)
+	assert.Equal(t, "escalation on signal user defined signal 2",
+		events[3].Reason)
+}
+
+func TestWorkerSignalForceExit(t *testing.T) {
+	code := make(chan int, 1)
+	exit = func(c int) {
+		code <- c
+	}
+	defer func() {
+		exit = os.Exit
+	}()
+
+	router := NewSignalRouter()
+	stop := make(chan struct{})
+	w := NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			<-stop
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			<-stop
+			return nil
+		},
+		Terminate: func(ctx context.Context) error {
+			<-stop
+			return nil
+		},
+	}, &ServiceOptions{
+		Signals:       []os.Signal{syscall.SIGUSR2},
+		ForceExitCode: 3,
+		SignalRouter:  router,
+	})
+	events := make(chan Event)
+	w.ObserveWithOptions(events, &ObserveOptions{Policy: ObserveUnbounded})
+	assert.NoError(t, w.StartBackground())
+
+	// An observer which never receives events does not prevent the exit
+	blocked := make(chan Event)
+	w.Observe(blocked)
+	for _, state := range []State{ShuttingDown, Terminating} {
+		router.Dispatch(syscall.SIGUSR2)
+		assert.Eventually(t, func() bool {
+			return w.State() == state
+		}, time.Second, time.Millisecond)
+	}
+	router.Dispatch(syscall.SIGUSR2)
+	assert.Equal(t, 3, <-code)
+
+	// The event is delivered once the observer is removed
+	w.Unobserve(blocked)
+	var reasons []string
+	for event := range events {
+		reasons = append(reasons, event.Reason)
+		if event.To == Terminating && event.From == Terminating {
+			break
+		}
+	}
+	assert.Equal(t, []string{"", "", "signal user defined signal 2",
+		"escalation on signal user defined signal 2",
+		"forced exit on signal user defined signal 2"}, reasons)
+	close(stop)
+}
+
+func TestWorkerSignalForceExitEvent(t *testing.T) {
+	code := make(chan int, 1)
+	exit = func(c int) {
+		code <- c
+	}
+	defer func() {
+		exit = os.Exit
+	}()
+
+	router := NewSignalRouter()
+	stop := make(chan struct{})
+	defer close(stop)
+	w := NewWorkerWithOptions(&Hooks{
+		Name: "worker",
+		Start: func(ctx context.Context) error {
+			<-stop
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			<-stop
+			return nil
+		},
+		Terminate: func(ctx context.Context) error {
+			<-stop
+			return nil
+		},
+	}, &ServiceOptions{
+		Signals:       []os.Signal{syscall.SIGUSR2},
+		ForceExitCode: 3,
+		SignalRouter:  router,
+	})
+	events := make(chan Event, 16)
+	w.Observe(events)
+	assert.NoError(t, w.StartBackground())
+	for i := 0; i < 3; i++ {
+		router.Dispatch(syscall.SIGUSR2)
+	}
+	assert.Equal(t, 3, <-code)
+
+	// The event was delivered before exiting
+	var reasons []string
+	for len(events) > 0 {
+		reasons = append(reasons, (<-events).Reason)
+	}
+	assert.Contains(t, reasons, "forced exit on signal user defined signal 2")
+}
+
+func TestWorkerSignalTable(t *testing.T) {
+	var dumps int32
+	s := newTestWorker("worker", 0, time.Second, nil)
+	s.opts.SignalActions = map[os.Signal]Action{syscall.SIGUSR2: Terminate}
+	s.opts.SignalHandlers = map[os.Signal]SignalHandler{
+		syscall.SIGUSR1: func(w *Worker, sig os.Signal) {
+			DumpState(w, sig)
+			atomic.AddInt32(&dumps, 1)
+		},
+	}
+	assert.NoError(t, s.doStart())
+	for i := 0; i < 2; i++ {
+		s.signal(syscall.SIGUSR1)
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&dumps) == int32(i+1)
+		}, time.Second, time.Millisecond)
+	}
+	assert.Equal(t, Started, s.State())
+	s.signal(syscall.SIGUSR2)
+	assert.Equal(t, []State{Starting, Started, Terminating, Stopped},
+		s.ObserverEventSequence())
+}
+
+func TestEventMetadata(t *testing.T) {
+	type key struct{}
+	s := newTestWorker("worker", 0, time.Second, Wait(5*time.Millisecond))
+	ctx := context.WithValue(context.Background(), key{}, "start")
+	assert.NoError(t, s.StartBackgroundCtx(ctx))
+	<-time.After(5 * time.Millisecond)
+	assert.NoError(t, s.ShutdownCtx(
+		context.WithValue(context.Background(), key{}, "shutdown")))
+	events := s.ObserverEvents()
+	assert.Len(t, events, 4)
+	for i, event := range events {
+		assert.Equal(t, "worker", event.Name)
+		assert.Equal(t, uint64(i+1), event.Seq)
+		assert.False(t, event.Time.IsZero())
+		if i > 0 {
+			assert.False(t, event.Time.Before(events[i-1].Time))
+			assert.Equal(t, event.Time.Sub(events[i-1].Time), event.Duration)
+		}
+	}
+	assert.True(t, events[1].Duration >= 5*time.Millisecond)
+	assert.True(t, events[2].Duration >= 5*time.Millisecond)
+	assert.Equal(t, "start", events[1].Context.Value(key{}))
+	assert.Equal(t, "shutdown", events[2].Context.Value(key{}))
+}
+
+func TestOnTransition(t *testing.T) {
+	s := newTestWorker("worker", 0, time.Second, nil)
+	var (
+		all     []State
+		stopped []State
+		calls   int32
+		done    = make(chan struct{})
+	)
+	s.OnTransition(func(event Event) {
+		all = append(all, event.To)
+	})
+	s.OnTransition(func(event Event) {
+		stopped = append(stopped, event.To)
+		close(done)
+	}, Stopped, Error)
+	unsubscribe := s.OnTransition(func(event Event) {
+		atomic.AddInt32(&calls, 1)
+	})
+	unsubscribe()
+	unsubscribe()
+
+	assert.NoError(t, s.doStart())
+	assert.NoError(t, s.Shutdown())
+	<-done
+	assert.Equal(t, []State{Starting, Started, ShuttingDown, Stopped}, all)
+	assert.Equal(t, []State{Stopped}, stopped)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
+
+func TestObserveKeepOpen(t *testing.T) {
+	s1 := newTestWorker("s1", 0, time.Second, nil)
+	s2 := newTestWorker("s2", 0, time.Second, nil)
+	ch := make(chan Event, 16)
+	opts := &ObserveOptions{KeepOpen: true}
+	s1.ObserveWithOptions(ch, opts)
+	s2.ObserveWithOptions(ch, opts)
+	assert.NoError(t, s1.doStart())
+	assert.NoError(t, s2.doStart())
+	assert.NoError(t, s1.Shutdown())
+	assert.NoError(t, s2.Shutdown())
+	<-s1.Done()
+	<-s2.Done()
+	s1.Unobserve(ch)
+	s2.Unobserve(ch)
+	assert.Len(t, ch, 8)
+}
+
 // Event observer
 type eventObserver struct {
 	events []Event
@@ -213,6 +1142,7 @@ func newTestWorker(name string, shutdownDelay time.Duration,
 				ShutdownTimeout: shutdownTimeout,
 				Logger:          simpleLogger{},
 				Signals:         []os.Signal{syscall.SIGUSR2},
+				SignalRouter:    NewSignalRouter(),
 			},
 		),
 		eventObserver: newEventObserver(),
@@ -223,6 +1153,11 @@ func newTestWorker(name string, shutdownDelay time.Duration,
 	return s
 }
 
+// signal injects a signal into the signal router of the worker.
+func (s *testWorker) signal(sig os.Signal) {
+	s.opts.SignalRouter.Dispatch(sig)
+}
+
 func (s *testWorker) doStart() error {
 	ch := make(chan error)
 	go func() {